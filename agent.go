@@ -0,0 +1,247 @@
+package avs
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AudioSource opens a new audio stream from the device's microphone, e.g.,
+// when AVS expects the user to speak. The stream should be 16 kHz, mono,
+// 16-bit little-endian PCM (unless another RecognizeFormat is used), and it
+// should end when the user stops speaking.
+type AudioSource func() (io.ReadCloser, error)
+
+// AudioSink plays audio on the device (e.g., through its speaker).
+type AudioSink interface {
+	// Play plays the audio and blocks until it has finished playing or until
+	// Stop has been called. The format is either an AVS format (e.g.,
+	// "AUDIO_MPEG") or, for remote streams and built-in sounds, a MIME type.
+	Play(format string, audio io.Reader) error
+	// Stop interrupts any audio that is currently playing.
+	Stop() error
+}
+
+// AudioSeeker may be implemented by an AudioSink that is able to start playing
+// audio from an offset. If an AudioSink doesn't implement it, audio that is
+// resumed will be played from the start.
+type AudioSeeker interface {
+	PlayFrom(format string, audio io.Reader, offset time.Duration) error
+}
+
+// Plays audio through the sink, starting at the offset if possible.
+func playFrom(sink AudioSink, format string, audio io.Reader, offset time.Duration) error {
+	if seeker, ok := sink.(AudioSeeker); ok && offset > 0 {
+		return seeker.PlayFrom(format, audio, offset)
+	}
+	return sink.Play(format, audio)
+}
+
+// PCMSink is an AudioSink for devices that can only play PCM audio. It
+// decodes MP3 audio (the format of Speak directives and of most streams) and
+// plays it through Sink as a WAV file. Audio in other formats is passed
+// through unchanged.
+type PCMSink struct {
+	Sink AudioSink
+}
+
+// Play plays the audio through s.Sink, decoding it first if it's MP3 audio.
+func (s PCMSink) Play(format string, audio io.Reader) error {
+	return s.PlayFrom(format, audio, 0)
+}
+
+// PlayFrom is like Play, but skips the audio before the offset.
+func (s PCMSink) PlayFrom(format string, audio io.Reader, offset time.Duration) error {
+	if !isMP3Format(format) {
+		return playFrom(s.Sink, format, audio, offset)
+	}
+	decoder, err := NewMP3Decoder(audio)
+	if err != nil {
+		return err
+	}
+	f := decoder.Format()
+	skip := int64(offset.Seconds()*float64(f.SampleRate)) * int64(f.Channels*f.BitsPerSample/8)
+	if _, err := io.CopyN(ioutil.Discard, decoder, skip); err != nil && err != io.EOF {
+		return err
+	}
+	return s.Sink.Play("audio/wav", WAVAudio(decoder, f))
+}
+
+// Stop stops s.Sink.
+func (s PCMSink) Stop() error {
+	return s.Sink.Stop()
+}
+
+// SharedSink lets several capability agents play audio through the same
+// AudioSink. Each agent should be given its own channel of the SharedSink (see
+// Channel) so that an agent that stops its audio (e.g., an alert that goes to
+// the background) doesn't stop the audio of other agents.
+type SharedSink struct {
+	Sink AudioSink
+
+	mu      sync.Mutex
+	playing map[*stoppableReader]*sharedSinkChannel
+}
+
+// NewSharedSink returns a SharedSink which plays audio through the provided
+// sink.
+func NewSharedSink(sink AudioSink) *SharedSink {
+	return &SharedSink{
+		Sink:    sink,
+		playing: make(map[*stoppableReader]*sharedSinkChannel),
+	}
+}
+
+// Channel returns a new AudioSink which plays audio through the shared sink.
+// Stopping it only stops the audio that was played through it. The shared sink
+// itself is only stopped if no other channel is playing; otherwise, the audio
+// of the channel ends as soon as the shared sink reads from it again.
+func (s *SharedSink) Channel() AudioSink {
+	return &sharedSinkChannel{s}
+}
+
+type sharedSinkChannel struct {
+	shared *SharedSink
+}
+
+// Play implements the AudioSink interface.
+func (c *sharedSinkChannel) Play(format string, audio io.Reader) error {
+	return c.PlayFrom(format, audio, 0)
+}
+
+// PlayFrom implements the AudioSeeker interface. If the shared sink isn't an
+// AudioSeeker, the audio is played from the start.
+func (c *sharedSinkChannel) PlayFrom(format string, audio io.Reader, offset time.Duration) error {
+	s := c.shared
+	r := &stoppableReader{r: audio}
+	s.mu.Lock()
+	s.playing[r] = c
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.playing, r)
+		s.mu.Unlock()
+	}()
+	return playFrom(s.Sink, format, r, offset)
+}
+
+// Stop implements the AudioSink interface.
+func (c *sharedSinkChannel) Stop() error {
+	s := c.shared
+	s.mu.Lock()
+	mine, others := 0, 0
+	for r, channel := range s.playing {
+		if channel != c {
+			others++
+			continue
+		}
+		r.stop()
+		mine++
+	}
+	s.mu.Unlock()
+	if mine == 0 || others > 0 {
+		return nil
+	}
+	return s.Sink.Stop()
+}
+
+// A reader which ends early once it has been stopped.
+type stoppableReader struct {
+	r       io.Reader
+	mu      sync.Mutex
+	stopped bool
+}
+
+func (r *stoppableReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	stopped := r.stopped
+	r.mu.Unlock()
+	if stopped {
+		return 0, io.EOF
+	}
+	return r.r.Read(p)
+}
+
+func (r *stoppableReader) stop() {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+}
+
+// Returns whether the format (an AVS format or a MIME type) is MP3.
+func isMP3Format(format string) bool {
+	if mediatype, _, err := mime.ParseMediaType(format); err == nil {
+		format = mediatype
+	}
+	switch strings.ToLower(format) {
+	case "audio_mpeg", "audio/mpeg", "audio/mp3", "audio/mpeg3", "audio/x-mpeg":
+		return true
+	}
+	return false
+}
+
+// EventSender sends events to AVS. It's used by the capability agents in this
+// package to report state changes.
+type EventSender interface {
+	SendEvent(event TypedMessage) (*Response, error)
+}
+
+// The EventSenderFunc type is an adapter to allow the use of ordinary
+// functions as event senders.
+type EventSenderFunc func(event TypedMessage) (*Response, error)
+
+// SendEvent calls f(event).
+func (f EventSenderFunc) SendEvent(event TypedMessage) (*Response, error) {
+	return f(event)
+}
+
+// RequestSender may be implemented by an EventSender that is able to send a
+// whole Request, so that capability agents can attach context to their events
+// themselves.
+type RequestSender interface {
+	// SendRequest sends the request, filling in its access token.
+	SendRequest(request *Request) (*Response, error)
+}
+
+// Sends an event along with context. If the sender isn't a RequestSender, only
+// the event is sent, and the sender is responsible for attaching the context.
+func sendEventWithContext(sender EventSender, event TypedMessage, context ...TypedMessage) (*Response, error) {
+	rs, ok := sender.(RequestSender)
+	if !ok {
+		return sender.SendEvent(event)
+	}
+	request := NewRequest("")
+	request.Event = event
+	for _, m := range context {
+		request.AddContext(m)
+	}
+	return rs.SendRequest(request)
+}
+
+// EventSender returns an EventSender which posts events to AVS on behalf of
+// the user that the access token belongs to. It's also a RequestSender.
+func (c *Client) EventSender(accessToken string) EventSender {
+	return &clientEventSender{c, accessToken}
+}
+
+type clientEventSender struct {
+	client      *Client
+	accessToken string
+}
+
+// SendEvent implements the EventSender interface.
+func (s *clientEventSender) SendEvent(event TypedMessage) (*Response, error) {
+	request := NewRequest("")
+	request.Event = event
+	return s.SendRequest(request)
+}
+
+// SendRequest implements the RequestSender interface.
+func (s *clientEventSender) SendRequest(request *Request) (*Response, error) {
+	r := *request
+	r.AccessToken = s.accessToken
+	return s.client.Do(&r)
+}
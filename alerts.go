@@ -0,0 +1,375 @@
+package avs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MaxAlertDuration is how long an alert keeps sounding if the user doesn't stop
+// it.
+const MaxAlertDuration = time.Hour
+
+// DefaultAlertTone is a simple beeping sound which can be used as the tone of
+// alerts. Its format is DefaultAlertToneFormat.
+var DefaultAlertTone = newBeep(880, 200*time.Millisecond, 3)
+
+// DefaultAlertToneFormat is the format of DefaultAlertTone.
+const DefaultAlertToneFormat = "audio/wav"
+
+// The largest alert asset that is downloaded.
+const maxAlertAssetSize = 10 << 20
+
+// Creates a WAV file (16 kHz, mono, 16-bit) with a number of beeps of the
+// provided frequency, separated by silence of the same length as a beep.
+func newBeep(frequency float64, length time.Duration, beeps int) []byte {
+	const rate = 16000
+	n := int(length.Seconds() * rate)
+	samples := make([]byte, 0, 2*2*n*beeps)
+	for b := 0; b < beeps; b++ {
+		for i := 0; i < 2*n; i++ {
+			var v int16
+			if i < n {
+				v = int16(8000 * math.Sin(2*math.Pi*frequency*float64(i)/rate))
+			}
+			samples = append(samples, byte(v), byte(v>>8))
+		}
+	}
+	var buf bytes.Buffer
+	writeWAVHeader(&buf, RecognizeAudioFormat, uint32(len(samples)))
+	buf.Write(samples)
+	return buf.Bytes()
+}
+
+// Alerts keeps track of the alerts set by AVS and sounds them through an
+// AudioSink when they go off, reporting the Alerts events to AVS.
+type Alerts struct {
+	Sender EventSender
+	Sink   AudioSink
+	Clock  Clock
+	// Focus is optional. If it's set, alerts use the alerts channel and are
+	// silenced while they're in the background.
+	Focus *FocusManager
+	// The audio which is played repeatedly while an alert is active, in the
+	// format specified by ToneFormat. It's also played for alerts with assets
+	// if the assets can't be downloaded.
+	Tone       []byte
+	ToneFormat string
+	// HTTPClient is used to download the assets of alerts. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	alerts map[string]*scheduledAlert
+	active *scheduledAlert
+}
+
+type scheduledAlert struct {
+	Alert
+	// Closed when the alert is deleted or stopped.
+	cancel chan struct{}
+	focus  *AlertFocus
+	// Whether the alert is in the foreground, so that its sounds should be
+	// playing.
+	sounding bool
+	// The audio played in the foreground, in order, and in the background.
+	// They're set once the assets have been downloaded; until then, the tone is
+	// played.
+	sounds     []alertSound
+	background *alertSound
+}
+
+// Audio that is played when an alert goes off.
+type alertSound struct {
+	format string
+	data   []byte
+}
+
+// NewAlerts returns an Alerts which sounds alerts with the provided tone.
+func NewAlerts(sender EventSender, sink AudioSink, tone []byte, toneFormat string) *Alerts {
+	return &Alerts{
+		Sender:     sender,
+		Sink:       sink,
+		Clock:      SystemClock,
+		Tone:       tone,
+		ToneFormat: toneFormat,
+		alerts:     make(map[string]*scheduledAlert),
+	}
+}
+
+// SetAlert applies the SetAlert directive. An existing alert with the same
+// token is replaced.
+func (a *Alerts) SetAlert(d *SetAlert) error {
+	scheduled, err := d.Payload.ScheduledAt()
+	if err != nil {
+		_, sendErr := a.Sender.SendEvent(NewSetAlertFailed(RandomUUIDString(), d.Payload.Token))
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	alert := &scheduledAlert{Alert: d.Payload, cancel: make(chan struct{})}
+	a.mu.Lock()
+	previous := a.alerts[alert.Token]
+	a.mu.Unlock()
+	if previous != nil {
+		a.stop(previous)
+	}
+	a.mu.Lock()
+	a.alerts[alert.Token] = alert
+	a.mu.Unlock()
+	go a.wait(alert, scheduled)
+	_, err = a.Sender.SendEvent(NewSetAlertSucceeded(RandomUUIDString(), alert.Token))
+	return err
+}
+
+// DeleteAlert applies the DeleteAlert directive. The alert is stopped if it's
+// currently active.
+func (a *Alerts) DeleteAlert(d *DeleteAlert) error {
+	token := d.Payload.Token
+	a.mu.Lock()
+	alert, ok := a.alerts[token]
+	a.mu.Unlock()
+	if !ok {
+		_, err := a.Sender.SendEvent(NewDeleteAlertFailed(RandomUUIDString(), token))
+		return err
+	}
+	if err := a.stop(alert); err != nil {
+		return err
+	}
+	_, err := a.Sender.SendEvent(NewDeleteAlertSucceeded(RandomUUIDString(), token))
+	return err
+}
+
+// StopActive stops the alert that is currently sounding, e.g., because the
+// user pressed a stop button on the device.
+func (a *Alerts) StopActive() error {
+	a.mu.Lock()
+	alert := a.active
+	a.mu.Unlock()
+	if alert == nil {
+		return nil
+	}
+	return a.stop(alert)
+}
+
+// AlertsState returns the current AlertsState context.
+func (a *Alerts) AlertsState() *AlertsState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	all := []Alert{}
+	for _, alert := range a.alerts {
+		all = append(all, alert.Alert)
+	}
+	active := []Alert{}
+	if a.active != nil {
+		active = append(active, a.active.Alert)
+	}
+	return NewAlertsState(all, active)
+}
+
+// Context implements the ContextProvider interface.
+func (a *Alerts) Context() TypedMessage {
+	return a.AlertsState()
+}
+
+// Waits for the alert to go off, or for it to be canceled. The assets of the
+// alert are downloaded in the meantime.
+func (a *Alerts) wait(alert *scheduledAlert, scheduled time.Time) {
+	go a.fetchAssets(alert)
+	select {
+	case <-alert.cancel:
+		return
+	case <-a.Clock.After(scheduled.Sub(a.Clock.Now())):
+	}
+	a.mu.Lock()
+	if a.alerts[alert.Token] != alert {
+		a.mu.Unlock()
+		return
+	}
+	previous := a.active
+	a.mu.Unlock()
+	if previous != nil {
+		a.stop(previous)
+	}
+	a.mu.Lock()
+	a.active = alert
+	alert.sounding = true
+	if a.Focus != nil {
+		alert.focus = &AlertFocus{
+			Sender: a.Sender,
+			Token:  alert.Token,
+			OnChange: func(state FocusState) error {
+				return a.setSounding(alert, state == FocusStateForeground)
+			},
+		}
+	}
+	a.mu.Unlock()
+	a.Sender.SendEvent(NewAlertStarted(RandomUUIDString(), alert.Token))
+	if alert.focus != nil {
+		a.Focus.Acquire(FocusChannelAlerts, alert.focus)
+	}
+	go a.sound(alert)
+	select {
+	case <-alert.cancel:
+	case <-a.Clock.After(MaxAlertDuration):
+		a.stop(alert)
+	}
+}
+
+// Plays the sounds of the active alert until it's stopped or, if the alert
+// has a loop count, until they've been played that many times.
+func (a *Alerts) sound(alert *scheduledAlert) {
+	pause := alert.LoopPause()
+	if pause <= 0 {
+		pause = time.Second
+	}
+	// Only the loops in which the alert was sounding in the foreground from
+	// start to end count toward the loop count.
+	for loop := 0; alert.LoopCount <= 0 || loop < alert.LoopCount; {
+		a.mu.Lock()
+		sounding := alert.sounding
+		sounds := alert.sounds
+		if sounds == nil {
+			// The assets haven't been downloaded yet.
+			sounds = []alertSound{{a.ToneFormat, a.Tone}}
+		}
+		if !sounding {
+			sounds = nil
+			if alert.background != nil {
+				sounds = []alertSound{*alert.background}
+			}
+		}
+		a.mu.Unlock()
+		played := sounding
+		for _, sound := range sounds {
+			select {
+			case <-alert.cancel:
+				return
+			default:
+			}
+			if sounding {
+				a.mu.Lock()
+				stillSounding := alert.sounding
+				a.mu.Unlock()
+				if !stillSounding {
+					// The alert went to the background partway through.
+					played = false
+					break
+				}
+			}
+			a.Sink.Play(sound.format, bytes.NewReader(sound.data))
+		}
+		if played {
+			loop++
+		}
+		select {
+		case <-alert.cancel:
+			return
+		case <-a.Clock.After(pause):
+		}
+	}
+	a.stop(alert)
+}
+
+// Downloads the assets of the alert in their play order. If an asset can't be
+// downloaded, the alert falls back to the tone.
+func (a *Alerts) fetchAssets(alert *scheduledAlert) {
+	tone := []alertSound{{a.ToneFormat, a.Tone}}
+	var sounds []alertSound
+	var background *alertSound
+	fetched := make(map[string]*alertSound)
+	fetch := func(id string) *alertSound {
+		if sound, ok := fetched[id]; ok {
+			return sound
+		}
+		var sound *alertSound
+		if asset := alert.Asset(id); asset != nil {
+			sound, _ = a.fetchAsset(*asset)
+		}
+		fetched[id] = sound
+		return sound
+	}
+	for _, id := range alert.AssetPlayOrder {
+		sound := fetch(id)
+		if sound == nil {
+			sounds = nil
+			break
+		}
+		sounds = append(sounds, *sound)
+	}
+	if len(sounds) == 0 {
+		sounds = tone
+	}
+	if alert.BackgroundAlertAsset != "" {
+		background = fetch(alert.BackgroundAlertAsset)
+	}
+	a.mu.Lock()
+	alert.sounds = sounds
+	alert.background = background
+	a.mu.Unlock()
+}
+
+// Downloads an asset.
+func (a *Alerts) fetchAsset(asset Asset) (*alertSound, error) {
+	resolver := &StreamResolver{Client: a.HTTPClient}
+	format, audio, _, err := resolver.Open(asset.URL, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(audio, maxAlertAssetSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAlertAssetSize {
+		return nil, fmt.Errorf("asset %s is too large", asset.AssetId)
+	}
+	return &alertSound{format, data}, nil
+}
+
+// Silences the alert while it's not in the foreground.
+func (a *Alerts) setSounding(alert *scheduledAlert, sounding bool) error {
+	a.mu.Lock()
+	wasSounding := alert.sounding
+	alert.sounding = sounding && a.active == alert
+	a.mu.Unlock()
+	if wasSounding && !sounding {
+		return a.Sink.Stop()
+	}
+	return nil
+}
+
+// Removes the alert and stops it if it's active.
+func (a *Alerts) stop(alert *scheduledAlert) error {
+	a.mu.Lock()
+	if a.alerts[alert.Token] != alert {
+		a.mu.Unlock()
+		return nil
+	}
+	delete(a.alerts, alert.Token)
+	close(alert.cancel)
+	wasActive := a.active == alert
+	wasSounding := alert.sounding
+	if wasActive {
+		a.active = nil
+		alert.sounding = false
+	}
+	a.mu.Unlock()
+	if !wasActive {
+		return nil
+	}
+	if wasSounding {
+		a.Sink.Stop()
+	}
+	if alert.focus != nil {
+		a.Focus.Release(FocusChannelAlerts, alert.focus)
+	}
+	_, err := a.Sender.SendEvent(NewAlertStopped(RandomUUIDString(), alert.Token))
+	return err
+}
@@ -0,0 +1,249 @@
+package avs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// AudioFormat describes the format of PCM audio.
+type AudioFormat struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	// Whether samples are IEEE floating point numbers rather than signed
+	// integers.
+	Float bool
+}
+
+// RecognizeAudioFormat is the audio format that AVS expects for Recognize
+// events (AUDIO_L16_RATE_16000_CHANNELS_1), always in little-endian byte order.
+var RecognizeAudioFormat = AudioFormat{
+	SampleRate:    16000,
+	Channels:      1,
+	BitsPerSample: 16,
+}
+
+// String returns a human readable description of the format.
+func (f AudioFormat) String() string {
+	kind := "integer"
+	if f.Float {
+		kind = "float"
+	}
+	return fmt.Sprintf("%d Hz, %d channel(s), %d-bit %s", f.SampleRate, f.Channels, f.BitsPerSample, kind)
+}
+
+// AudioFormatError is returned when audio is not in a format that AVS
+// supports and there's no converter that can convert it.
+type AudioFormatError struct {
+	Format AudioFormat
+}
+
+func (e *AudioFormatError) Error() string {
+	return fmt.Sprintf("unsupported audio format %s (expected %s)", e.Format, RecognizeAudioFormat)
+}
+
+// AudioConverter converts audio in the provided format to
+// RecognizeAudioFormat.
+type AudioConverter func(audio io.Reader, format AudioFormat) (io.Reader, error)
+
+// Values of the format tag in the fmt chunk of a WAV file.
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// The largest fmt chunk that ReadWAVHeader accepts. WAVE_FORMAT_EXTENSIBLE
+// chunks are 40 bytes.
+const maxWAVFormatSize = 64
+
+// ReadWAVHeader reads the header of a WAV file and returns the format of its
+// audio. After it returns, r is positioned at the start of the sample data.
+func ReadWAVHeader(r io.Reader) (AudioFormat, error) {
+	var format AudioFormat
+	var riff struct {
+		ID   [4]byte
+		Size uint32
+		Type [4]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &riff); err != nil {
+		return format, err
+	}
+	if string(riff.ID[:]) != "RIFF" || string(riff.Type[:]) != "WAVE" {
+		return format, errors.New("not a WAV file")
+	}
+	hasFormat := false
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return format, err
+		}
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			// Check the size before allocating, since it comes from the file.
+			if chunk.Size < 16 || chunk.Size > maxWAVFormatSize {
+				return format, errors.New("invalid WAV fmt chunk")
+			}
+			data := make([]byte, wavChunkSize(chunk.Size))
+			if _, err := io.ReadFull(r, data); err != nil {
+				return format, err
+			}
+			tag := binary.LittleEndian.Uint16(data[0:])
+			if tag == wavFormatExtensible && chunk.Size >= 26 {
+				// The actual format tag is the start of the sub-format GUID.
+				tag = binary.LittleEndian.Uint16(data[24:])
+			}
+			switch tag {
+			case wavFormatPCM:
+			case wavFormatFloat:
+				format.Float = true
+			default:
+				return format, fmt.Errorf("unsupported WAV format tag %#x", tag)
+			}
+			format.Channels = int(binary.LittleEndian.Uint16(data[2:]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(data[4:]))
+			format.BitsPerSample = int(binary.LittleEndian.Uint16(data[14:]))
+			hasFormat = true
+		case "data":
+			if !hasFormat {
+				return format, errors.New("missing WAV fmt chunk")
+			}
+			// The size of the data chunk is ignored so that streamed WAV files
+			// (which may not know their size) can be read until EOF.
+			return format, nil
+		default:
+			// Skip chunks such as LIST, including the padding byte.
+			if _, err := io.CopyN(ioutil.Discard, r, wavChunkSize(chunk.Size)); err != nil {
+				return format, err
+			}
+		}
+	}
+}
+
+// Returns the size of a chunk's data including the padding byte that follows
+// data of an odd size.
+func wavChunkSize(size uint32) int64 {
+	return int64(size) + int64(size%2)
+}
+
+// The size of the header written by writeWAVHeader.
+const wavHeaderSize = 44
+
+// Writes the header of a WAV file with the given size of sample data.
+func writeWAVHeader(w io.Writer, format AudioFormat, dataSize uint32) error {
+	tag := uint16(wavFormatPCM)
+	if format.Float {
+		tag = wavFormatFloat
+	}
+	blockAlign := format.Channels * format.BitsPerSample / 8
+	riffSize := uint32(wavHeaderSize - 8 + int64(dataSize))
+	if int64(dataSize)+wavHeaderSize-8 > math.MaxUint32 {
+		riffSize = math.MaxUint32
+	}
+	header := struct {
+		RIFF          [4]byte
+		RIFFSize      uint32
+		WAVE, Fmt     [4]byte
+		FmtSize       uint32
+		Tag, Channels uint16
+		Rate          uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		[4]byte{'R', 'I', 'F', 'F'}, riffSize,
+		[4]byte{'W', 'A', 'V', 'E'}, [4]byte{'f', 'm', 't', ' '},
+		16, tag, uint16(format.Channels),
+		uint32(format.SampleRate), uint32(format.SampleRate * blockAlign),
+		uint16(blockAlign), uint16(format.BitsPerSample),
+		[4]byte{'d', 'a', 't', 'a'}, dataSize,
+	}
+	return binary.Write(w, binary.LittleEndian, &header)
+}
+
+// WAVWriter writes PCM audio as a WAV file.
+type WAVWriter struct {
+	w      io.Writer
+	format AudioFormat
+	size   int64
+}
+
+// NewWAVWriter writes the header of a WAV file with audio in the format and
+// returns a writer for the samples. As the size of the audio isn't known yet,
+// the header declares the largest possible size, which is corrected by Close
+// if w is an io.WriteSeeker (such as an *os.File).
+func NewWAVWriter(w io.Writer, format AudioFormat) (*WAVWriter, error) {
+	if err := writeWAVHeader(w, format, math.MaxUint32-wavHeaderSize); err != nil {
+		return nil, err
+	}
+	return &WAVWriter{w: w, format: format}, nil
+}
+
+// Write writes samples in the format of the WAV file.
+func (w *WAVWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close updates the sizes in the header if possible. It doesn't close the
+// underlying writer.
+func (w *WAVWriter) Close() error {
+	seeker, ok := w.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	size := uint32(math.MaxUint32 - wavHeaderSize)
+	if w.size < int64(size) {
+		size = uint32(w.size)
+	}
+	if err := writeWAVHeader(seeker, w.format, size); err != nil {
+		return err
+	}
+	_, err := seeker.Seek(0, io.SeekEnd)
+	return err
+}
+
+// WAVAudio returns a reader of a WAV file with the PCM audio in the format.
+// The audio is streamed, so the header declares the largest possible size.
+func WAVAudio(audio io.Reader, format AudioFormat) io.Reader {
+	var header bytes.Buffer
+	writeWAVHeader(&header, format, math.MaxUint32-wavHeaderSize)
+	return io.MultiReader(&header, audio)
+}
+
+// RecognizeAudio returns a reader of the audio in RecognizeAudioFormat. If the
+// audio starts with a WAV header, the header is checked and removed, and
+// audio in other formats is converted with the converter (which may be nil).
+// Audio without a WAV header is assumed to already be in the right format.
+func RecognizeAudio(audio io.Reader, converter AudioConverter) (io.Reader, error) {
+	br := bufio.NewReader(audio)
+	if header, _ := br.Peek(12); len(header) < 12 || !bytes.Equal(header[0:4], []byte("RIFF")) || !bytes.Equal(header[8:12], []byte("WAVE")) {
+		return br, nil
+	}
+	format, err := ReadWAVHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if format == RecognizeAudioFormat {
+		return br, nil
+	}
+	if converter == nil {
+		return nil, &AudioFormatError{format}
+	}
+	return converter(br, format)
+}
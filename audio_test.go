@@ -0,0 +1,100 @@
+package avs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// Returns the start of a WAV file with the chunks, each of which is an id
+// followed by its declared size and data.
+func wavFile(chunks ...interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF\xff\xff\xff\xffWAVE")
+	for _, chunk := range chunks {
+		switch v := chunk.(type) {
+		case string:
+			b.WriteString(v)
+		case uint32:
+			binary.Write(&b, binary.LittleEndian, v)
+		case []byte:
+			b.Write(v)
+		}
+	}
+	return b.Bytes()
+}
+
+// Returns the data of a PCM fmt chunk.
+func wavFormat(tag uint16, channels, rate, bits int) []byte {
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint16(data[0:], tag)
+	binary.LittleEndian.PutUint16(data[2:], uint16(channels))
+	binary.LittleEndian.PutUint32(data[4:], uint32(rate))
+	binary.LittleEndian.PutUint32(data[8:], uint32(rate*channels*bits/8))
+	binary.LittleEndian.PutUint16(data[12:], uint16(channels*bits/8))
+	binary.LittleEndian.PutUint16(data[14:], uint16(bits))
+	return data
+}
+
+func TestReadWAVHeader(t *testing.T) {
+	var b bytes.Buffer
+	format := AudioFormat{SampleRate: 44100, Channels: 2, BitsPerSample: 24}
+	if err := writeWAVHeader(&b, format, 6); err != nil {
+		t.Fatal(err)
+	}
+	b.WriteString("sample")
+	r := bytes.NewReader(b.Bytes())
+	got, err := ReadWAVHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != format {
+		t.Errorf("got %s, want %s", got, format)
+	}
+	if r.Len() != 6 {
+		t.Errorf("%d bytes left after the header, want 6", r.Len())
+	}
+	// Other chunks, including ones of an odd size, are skipped.
+	data := wavFile(
+		"LIST", uint32(3), []byte("abc\x00"),
+		"fmt ", uint32(16), wavFormat(wavFormatFloat, 1, 48000, 32),
+		"data", uint32(0))
+	got, err = ReadWAVHeader(bytes.NewReader(data))
+	want := AudioFormat{SampleRate: 48000, Channels: 1, BitsPerSample: 32, Float: true}
+	if err != nil || got != want {
+		t.Errorf("got %s, %v; want %s", got, err, want)
+	}
+}
+
+func TestReadWAVHeaderInvalid(t *testing.T) {
+	pcm := wavFormat(wavFormatPCM, 1, 16000, 16)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not RIFF", []byte("RIFX\x00\x00\x00\x00WAVE")},
+		{"truncated chunk header", wavFile("fmt ", []byte{16, 0})},
+		{"truncated fmt chunk", wavFile("fmt ", uint32(16), pcm[:10])},
+		{"small fmt chunk", wavFile("fmt ", uint32(14), pcm[:14])},
+		// The size would overflow to zero if the padding were added to it.
+		{"huge fmt chunk", wavFile("fmt ", uint32(0xffffffff), pcm)},
+		{"large fmt chunk", wavFile("fmt ", uint32(maxWAVFormatSize+2), make([]byte, maxWAVFormatSize+2))},
+		{"huge other chunk", wavFile("LIST", uint32(0xffffffff), []byte("abc"))},
+		{"unsupported format", wavFile("fmt ", uint32(16), wavFormat(2, 1, 16000, 16), "data", uint32(0))},
+		{"missing fmt chunk", wavFile("data", uint32(0))},
+		{"missing data chunk", wavFile("fmt ", uint32(16), pcm)},
+	}
+	for _, test := range tests {
+		_, err := ReadWAVHeader(bytes.NewReader(test.data))
+		if err == nil {
+			t.Errorf("%s: got no error", test.name)
+		}
+	}
+	// A truncated file is reported as such rather than as a valid header.
+	_, err := ReadWAVHeader(bytes.NewReader(wavFile("fmt ", uint32(16), pcm[:10])))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
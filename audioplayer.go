@@ -0,0 +1,424 @@
+package avs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AudioPlayer plays the audio items of Play directives through an AudioSink,
+// maintains the play queue, and reports the AudioPlayer events to AVS.
+type AudioPlayer struct {
+	Sender EventSender
+	Sink   AudioSink
+	// Focus is optional. If it's set, the player uses the content channel and
+	// pauses while it's in the background.
+	Focus *FocusManager
+	// HTTPClient is used to fetch remote streams and playlists (see
+	// StreamResolver). If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// The version of the PlaybackController interface used for the events of
+	// playback buttons and toggles. If it's empty, version 1.1 is used.
+	PlaybackControllerVersion PlaybackControllerVersion
+
+	mu       sync.Mutex
+	queue    []AudioItem
+	content  map[string][]byte
+	current  *playback
+	token    string
+	activity PlayerActivity
+	offset   time.Duration
+	// The item that is paused because the content channel is in the
+	// background, and whether it has yet to be started.
+	paused       *AudioItem
+	pendingStart bool
+	hasFocus     bool
+}
+
+// A single attempt at playing an audio item.
+type playback struct {
+	item    AudioItem
+	started time.Time
+	offset  time.Duration
+	// The PlaybackStarted or PlaybackResumed event, which is sent before any
+	// other event of the playback.
+	startEvent TypedMessage
+	// Closed when the playback is interrupted by the player.
+	done chan struct{}
+}
+
+// NewAudioPlayer returns an idle AudioPlayer which plays audio through the
+// provided sink.
+func NewAudioPlayer(sender EventSender, sink AudioSink) *AudioPlayer {
+	return &AudioPlayer{
+		Sender:   sender,
+		Sink:     sink,
+		content:  make(map[string][]byte),
+		activity: PlayerActivityIdle,
+	}
+}
+
+// Play applies the Play directive. If the audio item is attached, it must be
+// in the content of the provided response.
+func (p *AudioPlayer) Play(d *Play, response *Response) error {
+	item := d.Payload.AudioItem
+	if cid := item.Stream.ContentId(); cid != "" {
+		if response == nil || response.Content[cid] == nil {
+			return fmt.Errorf("missing content %s", cid)
+		}
+		p.mu.Lock()
+		p.content[cid] = response.Content[cid]
+		p.mu.Unlock()
+	}
+	switch d.Payload.PlayBehavior {
+	case PlayBehaviorReplaceAll:
+		if err := p.stop(); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.queue = []AudioItem{item}
+	case PlayBehaviorReplaceEnqueued:
+		p.mu.Lock()
+		p.queue = []AudioItem{item}
+	case PlayBehaviorEnqueue:
+		p.mu.Lock()
+		if expected := item.Stream.ExpectedPreviousToken; expected != "" && expected != p.lastToken() {
+			// The item was meant to follow another item, which is no longer queued.
+			p.mu.Unlock()
+			return nil
+		}
+		p.queue = append(p.queue, item)
+	default:
+		return fmt.Errorf("unsupported play behavior %s", d.Payload.PlayBehavior)
+	}
+	idle := p.current == nil && p.paused == nil
+	p.mu.Unlock()
+	if idle {
+		p.next()
+	}
+	return nil
+}
+
+// Stop applies the Stop directive.
+func (p *AudioPlayer) Stop(d *Stop) error {
+	return p.stop()
+}
+
+// ClearQueue applies the ClearQueue directive.
+func (p *AudioPlayer) ClearQueue(d *ClearQueue) error {
+	if d.Payload.ClearBehavior == ClearBehaviorClearAll {
+		if err := p.stop(); err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	p.queue = nil
+	p.mu.Unlock()
+	_, err := p.Sender.SendEvent(NewPlaybackQueueCleared(RandomUUIDString()))
+	return err
+}
+
+// PressButton tells AVS that the user pressed a playback button on the
+// device. AVS responds with the directives that apply it.
+//
+// The PlaybackState context of the player is attached to the event if the
+// sender is a RequestSender (such as a Device or a Client's EventSender).
+func (p *AudioPlayer) PressButton(button PlaybackButton) error {
+	event, err := NewPlaybackButtonEvent(p.PlaybackControllerVersion, RandomUUIDString(), button)
+	if err != nil {
+		return err
+	}
+	_, err = sendEventWithContext(p.Sender, event, p.PlaybackState())
+	return err
+}
+
+// Toggle tells AVS that the user selected or deselected a playback toggle
+// (such as shuffle) on the device. Toggles require version 1.1 of the
+// PlaybackController interface.
+func (p *AudioPlayer) Toggle(toggle PlaybackToggle, selected bool) error {
+	if p.PlaybackControllerVersion == PlaybackControllerVersion10 {
+		return fmt.Errorf("toggle %s is not supported by PlaybackController %s", toggle, p.PlaybackControllerVersion)
+	}
+	action := ToggleActionDeselect
+	if selected {
+		action = ToggleActionSelect
+	}
+	_, err := sendEventWithContext(p.Sender, NewToggleCommandIssued(RandomUUIDString(), toggle, action), p.PlaybackState())
+	return err
+}
+
+// FocusChanged implements the FocusObserver interface.
+func (p *AudioPlayer) FocusChanged(state FocusState) error {
+	switch state {
+	case FocusStateForeground:
+		p.mu.Lock()
+		p.hasFocus = true
+		if p.paused == nil {
+			p.mu.Unlock()
+			return nil
+		}
+		item, offset, pendingStart := *p.paused, p.offset, p.pendingStart
+		p.paused = nil
+		p.pendingStart = false
+		if pendingStart {
+			p.start(item, offset, NewPlaybackStarted(RandomUUIDString(), item.Stream.Token, offset))
+		} else {
+			p.start(item, offset, NewPlaybackResumed(RandomUUIDString(), item.Stream.Token, offset))
+		}
+		p.mu.Unlock()
+		return nil
+	case FocusStateBackground:
+		p.mu.Lock()
+		p.hasFocus = true
+		if p.current == nil {
+			p.mu.Unlock()
+			return nil
+		}
+		item := p.current.item
+		token, offset := p.interrupt(PlayerActivityPaused)
+		p.paused = &item
+		p.mu.Unlock()
+		if err := p.Sink.Stop(); err != nil {
+			return err
+		}
+		_, err := p.Sender.SendEvent(NewPlaybackPaused(RandomUUIDString(), token, offset))
+		return err
+	case FocusStateNone:
+		p.mu.Lock()
+		p.hasFocus = false
+		p.mu.Unlock()
+		return p.stop()
+	}
+	return nil
+}
+
+// PlaybackState returns the current PlaybackState context.
+func (p *AudioPlayer) PlaybackState() *PlaybackState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	offset := p.offset
+	if p.current != nil {
+		offset = p.current.offset + time.Since(p.current.started)
+	}
+	return NewPlaybackState(p.token, offset, p.activity)
+}
+
+// Context implements the ContextProvider interface.
+func (p *AudioPlayer) Context() TypedMessage {
+	return p.PlaybackState()
+}
+
+// Stops the current audio item, reporting PlaybackStopped if it was playing or
+// paused.
+func (p *AudioPlayer) stop() error {
+	p.mu.Lock()
+	if p.current == nil && p.paused == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	wasPlaying := p.current != nil
+	wasStarted := !p.pendingStart
+	token, offset := p.interrupt(PlayerActivityStopped)
+	p.paused = nil
+	p.pendingStart = false
+	p.mu.Unlock()
+	if wasPlaying {
+		if err := p.Sink.Stop(); err != nil {
+			return err
+		}
+	}
+	p.releaseFocus()
+	if !wasStarted {
+		return nil
+	}
+	_, err := p.Sender.SendEvent(NewPlaybackStopped(RandomUUIDString(), token, offset))
+	return err
+}
+
+// Interrupts the current playback (if any) and returns its token and offset.
+//
+// This method must be called with p.mu held.
+func (p *AudioPlayer) interrupt(activity PlayerActivity) (token string, offset time.Duration) {
+	if p.current != nil {
+		p.offset = p.current.offset + time.Since(p.current.started)
+		close(p.current.done)
+		p.current = nil
+	}
+	p.activity = activity
+	return p.token, p.offset
+}
+
+// Starts playing the next item in the queue, if there is one.
+func (p *AudioPlayer) next() {
+	p.mu.Lock()
+	if len(p.queue) == 0 {
+		p.mu.Unlock()
+		p.releaseFocus()
+		return
+	}
+	item := p.queue[0]
+	p.queue = p.queue[1:]
+	p.token = item.Stream.Token
+	p.offset = item.Stream.Offset()
+	offset := p.offset
+	needsFocus := p.Focus != nil && !p.hasFocus
+	p.mu.Unlock()
+	if needsFocus {
+		p.Focus.Acquire(FocusChannelContent, p)
+	}
+	p.mu.Lock()
+	if p.Focus != nil && p.Focus.State(FocusChannelContent) != FocusStateForeground {
+		// Wait for the content channel to move to the foreground.
+		p.paused = &item
+		p.pendingStart = true
+		p.activity = PlayerActivityPaused
+		p.mu.Unlock()
+		return
+	}
+	p.start(item, offset, NewPlaybackStarted(RandomUUIDString(), item.Stream.Token, offset))
+	p.mu.Unlock()
+}
+
+// Starts playing the audio item in the background. The start event is sent
+// from the playback goroutine so that it always reaches AVS before the other
+// events of the playback.
+//
+// This method must be called with p.mu held.
+func (p *AudioPlayer) start(item AudioItem, offset time.Duration, startEvent TypedMessage) {
+	pb := &playback{
+		item:       item,
+		started:    time.Now(),
+		offset:     offset,
+		startEvent: startEvent,
+		done:       make(chan struct{}),
+	}
+	p.current = pb
+	p.activity = PlayerActivityPlaying
+	go p.play(pb)
+}
+
+// Plays an audio item and moves on to the next one when it finishes.
+func (p *AudioPlayer) play(pb *playback) {
+	token := pb.item.Stream.Token
+	p.Sender.SendEvent(pb.startEvent)
+	format, audio, skip, err := p.open(pb.item.Stream, pb.offset)
+	if err == nil {
+		if pb.offset == pb.item.Stream.Offset() {
+			// Let AVS know that the player is ready for the next item.
+			p.Sender.SendEvent(NewPlaybackNearlyFinished(RandomUUIDString(), token, pb.offset))
+		}
+		go p.reportProgress(pb)
+		err = playFrom(p.Sink, format, audio, skip)
+		audio.Close()
+	}
+	p.mu.Lock()
+	select {
+	case <-pb.done:
+		// Playback was interrupted and the events were already reported.
+		p.mu.Unlock()
+		return
+	default:
+	}
+	offset := pb.offset + time.Since(pb.started)
+	p.offset = offset
+	close(pb.done)
+	p.current = nil
+	delete(p.content, pb.item.Stream.ContentId())
+	if err != nil {
+		p.activity = PlayerActivityStopped
+	} else {
+		p.activity = PlayerActivityFinished
+	}
+	p.mu.Unlock()
+	if err != nil {
+		p.Sender.SendEvent(NewPlaybackFailed(RandomUUIDString(), token, mediaErrorType(err), err.Error()))
+	} else {
+		p.Sender.SendEvent(NewPlaybackFinished(RandomUUIDString(), token, offset))
+	}
+	p.next()
+}
+
+// Sends the progress reports requested by the stream until playback ends.
+func (p *AudioPlayer) reportProgress(pb *playback) {
+	report := pb.item.Stream.ProgressReport
+	token := pb.item.Stream.Token
+	delay := report.Delay() - pb.offset
+	if report.Delay() <= 0 || delay < 0 {
+		delay = -1
+	}
+	interval := report.Interval()
+	nextInterval := time.Duration(-1)
+	if interval > 0 {
+		nextInterval = interval - pb.offset%interval
+	}
+	for delay >= 0 || nextInterval >= 0 {
+		wait := delay
+		if wait < 0 || (nextInterval >= 0 && nextInterval < wait) {
+			wait = nextInterval
+		}
+		select {
+		case <-pb.done:
+			return
+		case <-time.After(wait):
+		}
+		offset := pb.offset + time.Since(pb.started)
+		if delay >= 0 {
+			delay -= wait
+			if delay <= 0 {
+				p.Sender.SendEvent(NewProgressReportDelayElapsed(RandomUUIDString(), token, offset))
+				delay = -1
+			}
+		}
+		if nextInterval >= 0 {
+			nextInterval -= wait
+			if nextInterval <= 0 {
+				p.Sender.SendEvent(NewProgressReportIntervalElapsed(RandomUUIDString(), token, offset))
+				nextInterval = interval
+			}
+		}
+	}
+}
+
+// Opens the audio of a stream, which is either attached or remote, and
+// returns the offset that the audio should be played from. Remote streams
+// that have expired fail with a StreamError.
+func (p *AudioPlayer) open(stream Stream, offset time.Duration) (format string, audio io.ReadCloser, rest time.Duration, err error) {
+	if cid := stream.ContentId(); cid != "" {
+		p.mu.Lock()
+		data, ok := p.content[cid]
+		p.mu.Unlock()
+		if !ok {
+			return "", nil, 0, fmt.Errorf("missing content %s", cid)
+		}
+		return "AUDIO_MPEG", ioutil.NopCloser(bytes.NewReader(data)), offset, nil
+	}
+	if stream.IsExpired(time.Now()) {
+		return "", nil, 0, &StreamError{MediaErrorTypeInvalidRequest, stream.URL, fmt.Errorf("expired at %s", stream.ExpiryTime)}
+	}
+	resolver := &StreamResolver{Client: p.HTTPClient}
+	return resolver.Open(stream.URL, offset)
+}
+
+// Returns the token of the last item in the queue, or the current one.
+//
+// This method must be called with p.mu held.
+func (p *AudioPlayer) lastToken() string {
+	if len(p.queue) > 0 {
+		return p.queue[len(p.queue)-1].Stream.Token
+	}
+	return p.token
+}
+
+func (p *AudioPlayer) releaseFocus() {
+	p.mu.Lock()
+	release := p.Focus != nil && p.hasFocus
+	p.hasFocus = false
+	p.mu.Unlock()
+	if release {
+		p.Focus.Release(FocusChannelContent, p)
+	}
+}
@@ -9,6 +9,9 @@ To send a Recognize event to AVS, you can use the PostRecognize function:
 	response, err := avs.PostRecognize(ACCESS_TOKEN, "abc123",
 	                                   "abc123dialog", audio)
 
+The audio may be raw 16 kHz, mono, 16-bit little-endian PCM or a WAV file in
+that format, in which case its header is removed before it's sent.
+
 For simple events you can use the PostEvent function:
 
 	event := NewVolumeChanged("abc123", 100, false)
@@ -49,6 +52,14 @@ use the CreateDownchannel method of the Client type:
 			fmt.Println("No code to handle directive:", d)
 		}
 	}
+
+To get a complete device which handles all directives, keeps its connection to
+AVS alive and sends the required context with events, use a Device:
+
+	device := avs.NewDevice(avs.StaticTokenSource(ACCESS_TOKEN), microphone, speaker)
+	go device.Run(nil)
+	audio, _ := microphone()
+	err := device.Listen(audio)
 */
 package avs
 
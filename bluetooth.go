@@ -0,0 +1,467 @@
+package avs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var errNoActiveBluetoothDevice = errors.New("no bluetooth device is connected")
+
+// BluetoothDevice identifies a Bluetooth device, such as a phone, that the
+// device can pair with.
+type BluetoothDevice struct {
+	// An id that the BluetoothAdapter assigns to the device, which stays the
+	// same across scans.
+	UniqueDeviceId      string             `json:"uniqueDeviceId"`
+	FriendlyName        string             `json:"friendlyName,omitempty"`
+	TruncatedMacAddress string             `json:"truncatedMacAddress,omitempty"`
+	SupportedProfiles   []BluetoothProfile `json:"supportedProfiles,omitempty"`
+}
+
+// Returns whether the device supports the profile with the name.
+func (d BluetoothDevice) supports(profile string) bool {
+	for _, p := range d.SupportedProfiles {
+		if p.Name == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// BluetoothProfile is a Bluetooth profile supported by a device, e.g., A2DP.
+type BluetoothProfile struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// BluetoothRequester specifies who asked for a change to a Bluetooth device.
+type BluetoothRequester string
+
+// Possible values for BluetoothRequester.
+const (
+	// BluetoothRequesterCloud means that the change was made by a directive.
+	BluetoothRequesterCloud = BluetoothRequester("CLOUD")
+	// BluetoothRequesterDevice means that the change was made on the device or
+	// by the other Bluetooth device.
+	BluetoothRequesterDevice = BluetoothRequester("DEVICE")
+)
+
+// BluetoothStreaming specifies whether the active device is streaming audio.
+type BluetoothStreaming string
+
+// Possible values for BluetoothStreaming.
+const (
+	BluetoothStreamingActive   = BluetoothStreaming("ACTIVE")
+	BluetoothStreamingPaused   = BluetoothStreaming("PAUSED")
+	BluetoothStreamingInactive = BluetoothStreaming("INACTIVE")
+)
+
+// BluetoothMediaCommand is a media control command for the active device.
+type BluetoothMediaCommand string
+
+// Possible values for BluetoothMediaCommand.
+const (
+	BluetoothMediaCommandPlay     = BluetoothMediaCommand("PLAY")
+	BluetoothMediaCommandStop     = BluetoothMediaCommand("STOP")
+	BluetoothMediaCommandNext     = BluetoothMediaCommand("NEXT")
+	BluetoothMediaCommandPrevious = BluetoothMediaCommand("PREVIOUS")
+)
+
+// BluetoothAdapter controls the device's Bluetooth radio. Devices are
+// identified by their UniqueDeviceId.
+type BluetoothAdapter interface {
+	// FriendlyName returns the name that other devices see.
+	FriendlyName() string
+	// PairedDevices returns the devices that are paired.
+	PairedDevices() ([]BluetoothDevice, error)
+	// Scan looks for devices that can be paired and returns them.
+	Scan() ([]BluetoothDevice, error)
+	// SetDiscoverable makes the device visible to other devices for the
+	// duration, or hides it.
+	SetDiscoverable(discoverable bool, duration time.Duration) error
+	Pair(id string) (BluetoothDevice, error)
+	Unpair(id string) error
+	// Connect connects to a paired device, disconnecting any other device.
+	Connect(id string) (BluetoothDevice, error)
+	Disconnect(id string) error
+	// MediaControl sends a media command to a connected device (e.g., over
+	// AVRCP).
+	MediaControl(id string, command BluetoothMediaCommand) error
+}
+
+// Bluetooth applies the Bluetooth directives with a BluetoothAdapter and
+// reports the results to AVS. Changes that happen on the adapter's side (such
+// as a phone connecting by itself) should be reported through Connected,
+// Disconnected and SetStreaming.
+type Bluetooth struct {
+	Sender  EventSender
+	Adapter BluetoothAdapter
+
+	mu        sync.Mutex
+	active    *BluetoothDevice
+	streaming BluetoothStreaming
+	// The paired devices, as of the last time they were loaded from the
+	// adapter, or nil if they haven't been loaded.
+	paired []BluetoothDevice
+}
+
+// NewBluetooth returns a Bluetooth which controls the provided adapter.
+func NewBluetooth(sender EventSender, adapter BluetoothAdapter) *Bluetooth {
+	return &Bluetooth{
+		Sender:    sender,
+		Adapter:   adapter,
+		streaming: BluetoothStreamingInactive,
+	}
+}
+
+// ScanDevices applies the ScanDevices directive.
+func (b *Bluetooth) ScanDevices(d *ScanDevices) error {
+	devices, err := b.Adapter.Scan()
+	if err != nil {
+		_, sendErr := b.Sender.SendEvent(NewScanDevicesFailed(RandomUUIDString()))
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	if devices == nil {
+		devices = []BluetoothDevice{}
+	}
+	b.refreshPaired()
+	_, err = b.Sender.SendEvent(NewScanDevicesReport(RandomUUIDString(), devices, false))
+	return err
+}
+
+// EnterDiscoverableMode applies the EnterDiscoverableMode directive.
+func (b *Bluetooth) EnterDiscoverableMode(d *EnterDiscoverableMode) error {
+	err := b.Adapter.SetDiscoverable(true, d.Duration())
+	event := TypedMessage(NewEnterDiscoverableModeSucceeded(RandomUUIDString()))
+	if err != nil {
+		event = NewEnterDiscoverableModeFailed(RandomUUIDString())
+	}
+	if _, sendErr := b.Sender.SendEvent(event); sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+// ExitDiscoverableMode applies the ExitDiscoverableMode directive.
+func (b *Bluetooth) ExitDiscoverableMode(d *ExitDiscoverableMode) error {
+	return b.Adapter.SetDiscoverable(false, 0)
+}
+
+// PairDevices applies the PairDevices directive.
+func (b *Bluetooth) PairDevices(d *PairDevices) error {
+	succeeded, failed := b.each(d.Payload.Devices, b.Adapter.Pair)
+	b.refreshPaired()
+	return b.report(
+		NewPairDevicesSucceeded(RandomUUIDString(), BluetoothRequesterCloud, succeeded), len(succeeded) > 0,
+		NewPairDevicesFailed(RandomUUIDString(), BluetoothRequesterCloud, failed), len(failed) > 0)
+}
+
+// UnpairDevices applies the UnpairDevices directive.
+func (b *Bluetooth) UnpairDevices(d *UnpairDevices) error {
+	succeeded, failed := b.each(d.Payload.Devices, func(id string) (BluetoothDevice, error) {
+		if err := b.Adapter.Unpair(id); err != nil {
+			return BluetoothDevice{}, err
+		}
+		b.clearActive(id)
+		return BluetoothDevice{UniqueDeviceId: id}, nil
+	})
+	b.refreshPaired()
+	return b.report(
+		NewUnpairDevicesSucceeded(RandomUUIDString(), BluetoothRequesterCloud, succeeded), len(succeeded) > 0,
+		NewUnpairDevicesFailed(RandomUUIDString(), BluetoothRequesterCloud, failed), len(failed) > 0)
+}
+
+// ConnectByDeviceIds applies the ConnectByDeviceIds directive. Since the
+// adapter disconnects any other device when it connects to one, the device
+// that was active before is reported as disconnected.
+func (b *Bluetooth) ConnectByDeviceIds(d *ConnectByDeviceIds) error {
+	b.mu.Lock()
+	previous := b.active
+	b.mu.Unlock()
+	succeeded, failed := b.each(d.Payload.Devices, func(id string) (BluetoothDevice, error) {
+		device, err := b.Adapter.Connect(id)
+		if err != nil {
+			return BluetoothDevice{}, err
+		}
+		b.setActive(device)
+		return device, nil
+	})
+	b.mu.Lock()
+	replaced := previous != nil && (b.active == nil || b.active.UniqueDeviceId != previous.UniqueDeviceId)
+	b.mu.Unlock()
+	if replaced {
+		_, err := b.Sender.SendEvent(NewDisconnectDevicesSucceeded(RandomUUIDString(), BluetoothRequesterCloud, []BluetoothDevice{*previous}))
+		if err != nil {
+			return err
+		}
+	}
+	return b.report(
+		NewConnectByDeviceIdsSucceeded(RandomUUIDString(), BluetoothRequesterCloud, succeeded), len(succeeded) > 0,
+		NewConnectByDeviceIdsFailed(RandomUUIDString(), BluetoothRequesterCloud, failed), len(failed) > 0)
+}
+
+// ConnectByProfile applies the ConnectByProfile directive. The paired devices
+// that support the profile are tried in order until one of them connects.
+func (b *Bluetooth) ConnectByProfile(d *ConnectByProfile) error {
+	name := d.Payload.Profile.Name
+	b.mu.Lock()
+	previous := b.active
+	b.mu.Unlock()
+	var connected *BluetoothDevice
+	var err error
+	for _, candidate := range b.pairedDevices() {
+		if !candidate.supports(name) {
+			continue
+		}
+		device, connectErr := b.Adapter.Connect(candidate.UniqueDeviceId)
+		if connectErr != nil {
+			err = connectErr
+			continue
+		}
+		connected = &device
+		break
+	}
+	if connected == nil {
+		if err == nil {
+			err = fmt.Errorf("no paired bluetooth device supports %s", name)
+		}
+		if _, sendErr := b.Sender.SendEvent(NewConnectByProfileFailed(RandomUUIDString(), BluetoothRequesterCloud, name)); sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	b.setActive(*connected)
+	if previous != nil && previous.UniqueDeviceId != connected.UniqueDeviceId {
+		_, err := b.Sender.SendEvent(NewDisconnectDevicesSucceeded(RandomUUIDString(), BluetoothRequesterCloud, []BluetoothDevice{*previous}))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = b.Sender.SendEvent(NewConnectByProfileSucceeded(RandomUUIDString(), BluetoothRequesterCloud, name, *connected))
+	return err
+}
+
+// DisconnectDevices applies the DisconnectDevices directive.
+func (b *Bluetooth) DisconnectDevices(d *DisconnectDevices) error {
+	succeeded, failed := b.each(d.Payload.Devices, func(id string) (BluetoothDevice, error) {
+		if err := b.Adapter.Disconnect(id); err != nil {
+			return BluetoothDevice{}, err
+		}
+		return b.clearActive(id), nil
+	})
+	return b.report(
+		NewDisconnectDevicesSucceeded(RandomUUIDString(), BluetoothRequesterCloud, succeeded), len(succeeded) > 0,
+		NewDisconnectDevicesFailed(RandomUUIDString(), BluetoothRequesterCloud, failed), len(failed) > 0)
+}
+
+// MediaControl applies the Bluetooth.Play, Bluetooth.Stop, Bluetooth.Next and
+// Bluetooth.Previous directives.
+func (b *Bluetooth) MediaControl(d *BluetoothMediaControl) error {
+	device := d.Payload.Device
+	if device.UniqueDeviceId == "" {
+		b.mu.Lock()
+		if b.active != nil {
+			device = *b.active
+		}
+		b.mu.Unlock()
+	}
+	command := d.Command()
+	err := errNoActiveBluetoothDevice
+	if device.UniqueDeviceId != "" {
+		err = b.Adapter.MediaControl(device.UniqueDeviceId, command)
+	}
+	var event TypedMessage
+	switch command {
+	case BluetoothMediaCommandPlay:
+		event = NewMediaControlPlaySucceeded(RandomUUIDString(), device)
+		if err != nil {
+			event = NewMediaControlPlayFailed(RandomUUIDString(), device)
+		}
+	case BluetoothMediaCommandStop:
+		event = NewMediaControlStopSucceeded(RandomUUIDString(), device)
+		if err != nil {
+			event = NewMediaControlStopFailed(RandomUUIDString(), device)
+		}
+	case BluetoothMediaCommandNext:
+		event = NewMediaControlNextSucceeded(RandomUUIDString(), device)
+		if err != nil {
+			event = NewMediaControlNextFailed(RandomUUIDString(), device)
+		}
+	case BluetoothMediaCommandPrevious:
+		event = NewMediaControlPreviousSucceeded(RandomUUIDString(), device)
+		if err != nil {
+			event = NewMediaControlPreviousFailed(RandomUUIDString(), device)
+		}
+	default:
+		return fmt.Errorf("unsupported bluetooth media command %s", command)
+	}
+	if _, sendErr := b.Sender.SendEvent(event); sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+// Connected reports that a device connected without a directive, e.g.,
+// because the user connected to it from their phone.
+func (b *Bluetooth) Connected(device BluetoothDevice) error {
+	previous := b.setActive(device)
+	// The device may have paired itself before connecting.
+	b.refreshPaired()
+	if previous != nil {
+		_, err := b.Sender.SendEvent(NewDisconnectDevicesSucceeded(RandomUUIDString(), BluetoothRequesterDevice, []BluetoothDevice{*previous}))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := b.Sender.SendEvent(NewConnectByDeviceIdsSucceeded(RandomUUIDString(), BluetoothRequesterDevice, []BluetoothDevice{device}))
+	return err
+}
+
+// Disconnected reports that a device disconnected without a directive.
+func (b *Bluetooth) Disconnected(id string) error {
+	device := b.clearActive(id)
+	_, err := b.Sender.SendEvent(NewDisconnectDevicesSucceeded(RandomUUIDString(), BluetoothRequesterDevice, []BluetoothDevice{device}))
+	return err
+}
+
+// SetStreaming reports whether the active device is streaming audio. The
+// StreamingStarted and StreamingEnded events are sent when streaming starts
+// and stops.
+func (b *Bluetooth) SetStreaming(streaming BluetoothStreaming) error {
+	b.mu.Lock()
+	if b.active == nil {
+		b.mu.Unlock()
+		return errNoActiveBluetoothDevice
+	}
+	device := *b.active
+	wasActive := b.streaming == BluetoothStreamingActive
+	b.streaming = streaming
+	b.mu.Unlock()
+	isActive := streaming == BluetoothStreamingActive
+	var err error
+	if isActive && !wasActive {
+		_, err = b.Sender.SendEvent(NewStreamingStarted(RandomUUIDString(), device))
+	} else if wasActive && !isActive {
+		_, err = b.Sender.SendEvent(NewStreamingEnded(RandomUUIDString(), device))
+	}
+	return err
+}
+
+// PairedDevicesChanged reloads the paired devices from the adapter. It should
+// be called when devices are paired or unpaired without a directive.
+func (b *Bluetooth) PairedDevicesChanged() {
+	b.refreshPaired()
+}
+
+// BluetoothState returns the current BluetoothState context. The paired
+// devices are cached, so the adapter is only asked for them the first time.
+func (b *Bluetooth) BluetoothState() *BluetoothState {
+	paired := b.pairedDevices()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var active *BluetoothActiveDevice
+	if b.active != nil {
+		active = &BluetoothActiveDevice{*b.active, b.streaming}
+	}
+	return NewBluetoothState(b.Adapter.FriendlyName(), paired, active)
+}
+
+// Context implements the ContextProvider interface.
+func (b *Bluetooth) Context() TypedMessage {
+	return b.BluetoothState()
+}
+
+// Applies f to each device and returns the devices that it succeeded and
+// failed for.
+func (b *Bluetooth) each(devices []BluetoothDevice, f func(id string) (BluetoothDevice, error)) (succeeded, failed []BluetoothDevice) {
+	succeeded, failed = []BluetoothDevice{}, []BluetoothDevice{}
+	for _, device := range devices {
+		result, err := f(device.UniqueDeviceId)
+		if err != nil {
+			failed = append(failed, device)
+			continue
+		}
+		succeeded = append(succeeded, result)
+	}
+	return succeeded, failed
+}
+
+// Sends the events whose conditions are true.
+func (b *Bluetooth) report(succeeded TypedMessage, anySucceeded bool, failed TypedMessage, anyFailed bool) error {
+	if anySucceeded {
+		if _, err := b.Sender.SendEvent(succeeded); err != nil {
+			return err
+		}
+	}
+	if anyFailed {
+		if _, err := b.Sender.SendEvent(failed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the cached paired devices, loading them from the adapter the first
+// time.
+func (b *Bluetooth) pairedDevices() []BluetoothDevice {
+	b.mu.Lock()
+	loaded := b.paired != nil
+	b.mu.Unlock()
+	if !loaded {
+		b.refreshPaired()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.paired == nil {
+		return []BluetoothDevice{}
+	}
+	return b.paired
+}
+
+// Loads the paired devices from the adapter. They are left as they were if the
+// adapter fails.
+func (b *Bluetooth) refreshPaired() {
+	paired, err := b.Adapter.PairedDevices()
+	if err != nil {
+		return
+	}
+	if paired == nil {
+		paired = []BluetoothDevice{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paired = paired
+}
+
+// Makes the device the active device, and returns the device it replaced, if
+// there was another one.
+func (b *Bluetooth) setActive(device BluetoothDevice) *BluetoothDevice {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	previous := b.active
+	b.active = &device
+	b.streaming = BluetoothStreamingInactive
+	if previous == nil || previous.UniqueDeviceId == device.UniqueDeviceId {
+		return nil
+	}
+	return previous
+}
+
+// Clears the active device if it has the id, and returns the device (or just
+// its id, if it wasn't the active device).
+func (b *Bluetooth) clearActive(id string) BluetoothDevice {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.active == nil || b.active.UniqueDeviceId != id {
+		return BluetoothDevice{UniqueDeviceId: id}
+	}
+	device := *b.active
+	b.active = nil
+	b.streaming = BluetoothStreamingInactive
+	return device
+}
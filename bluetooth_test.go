@@ -0,0 +1,258 @@
+package avs
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var (
+	testPhone   = BluetoothDevice{UniqueDeviceId: "phone", FriendlyName: "Phone", SupportedProfiles: []BluetoothProfile{{Name: "A2DP-SOURCE"}, {Name: "AVRCP"}}}
+	testTablet  = BluetoothDevice{UniqueDeviceId: "tablet", FriendlyName: "Tablet", SupportedProfiles: []BluetoothProfile{{Name: "A2DP-SOURCE"}}}
+	testHeadset = BluetoothDevice{UniqueDeviceId: "headset", FriendlyName: "Headset", SupportedProfiles: []BluetoothProfile{{Name: "HFP"}}}
+)
+
+// An EventSender which records the events that are sent.
+type eventRecorder struct {
+	events []TypedMessage
+}
+
+func (r *eventRecorder) SendEvent(event TypedMessage) (*Response, error) {
+	r.events = append(r.events, event)
+	return nil, nil
+}
+
+// Returns the names of the recorded events and forgets them.
+func (r *eventRecorder) take() []string {
+	names := []string{}
+	for _, event := range r.events {
+		names = append(names, event.GetMessage().Header["name"])
+	}
+	r.events = nil
+	return names
+}
+
+// Returns the devices in the payload of a recorded event.
+func (r *eventRecorder) devices(i int) []BluetoothDevice {
+	data, _ := json.Marshal(r.events[i])
+	var event struct {
+		Payload struct {
+			Devices []BluetoothDevice `json:"devices"`
+		} `json:"payload"`
+	}
+	json.Unmarshal(data, &event)
+	return event.Payload.Devices
+}
+
+// Parses a Bluetooth directive with the name and JSON payload.
+func bluetoothDirective(name, payload string) TypedMessage {
+	return (&Message{
+		Header:  map[string]string{"namespace": "Bluetooth", "name": name},
+		Payload: json.RawMessage(payload),
+	}).Typed()
+}
+
+func newTestBluetooth() (*Bluetooth, *FakeBluetoothAdapter, *eventRecorder) {
+	adapter := NewFakeBluetoothAdapter("Speaker", testPhone, testTablet, testHeadset)
+	events := new(eventRecorder)
+	return NewBluetooth(events, adapter), adapter, events
+}
+
+func pair(t *testing.T, b *Bluetooth, events *eventRecorder, ids string) {
+	err := b.PairDevices(bluetoothDirective("PairDevices", `{"devices": [`+ids+`]}`).(*PairDevices))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.take()
+}
+
+func TestBluetoothPair(t *testing.T) {
+	b, _, events := newTestBluetooth()
+	if paired := b.BluetoothState().Payload.PairedDevices; len(paired) != 0 {
+		t.Fatalf("got %d paired devices before pairing, want none", len(paired))
+	}
+	err := b.PairDevices(bluetoothDirective("PairDevices", `{"devices": [{"uniqueDeviceId": "phone"}, {"uniqueDeviceId": "watch"}]}`).(*PairDevices))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := events.devices(0); !reflect.DeepEqual(got, []BluetoothDevice{testPhone}) {
+		t.Errorf("got paired devices %v, want %v", got, []BluetoothDevice{testPhone})
+	}
+	if got := events.devices(1); len(got) != 1 || got[0].UniqueDeviceId != "watch" {
+		t.Errorf("got failed devices %v, want the watch", got)
+	}
+	if got, want := events.take(), []string{"PairDevicesSucceeded", "PairDevicesFailed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	if paired := b.BluetoothState().Payload.PairedDevices; !reflect.DeepEqual(paired, []BluetoothDevice{testPhone}) {
+		t.Errorf("got paired devices %v in the context, want %v", paired, []BluetoothDevice{testPhone})
+	}
+	err = b.UnpairDevices(bluetoothDirective("UnpairDevices", `{"devices": [{"uniqueDeviceId": "phone"}]}`).(*UnpairDevices))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := events.take(), []string{"UnpairDevicesSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	if paired := b.BluetoothState().Payload.PairedDevices; len(paired) != 0 {
+		t.Errorf("got %d paired devices after unpairing, want none", len(paired))
+	}
+}
+
+func TestBluetoothConnect(t *testing.T) {
+	b, adapter, events := newTestBluetooth()
+	pair(t, b, events, `{"uniqueDeviceId": "phone"}, {"uniqueDeviceId": "tablet"}`)
+	connect := func(id string) {
+		err := b.ConnectByDeviceIds(bluetoothDirective("ConnectByDeviceIds", `{"devices": [{"uniqueDeviceId": "`+id+`"}]}`).(*ConnectByDeviceIds))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	connect("phone")
+	if got, want := events.take(), []string{"ConnectByDeviceIdsSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	// Connecting to another device replaces the active device, which is
+	// reported as disconnected.
+	connect("tablet")
+	if got := events.devices(0); !reflect.DeepEqual(got, []BluetoothDevice{testPhone}) {
+		t.Errorf("got disconnected devices %v, want %v", got, []BluetoothDevice{testPhone})
+	}
+	if got, want := events.take(), []string{"DisconnectDevicesSucceeded", "ConnectByDeviceIdsSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	if adapter.Connected() != "tablet" {
+		t.Errorf("got %q connected, want the tablet", adapter.Connected())
+	}
+	active := b.BluetoothState().Payload.ActiveDevice
+	if active == nil || active.UniqueDeviceId != "tablet" {
+		t.Errorf("got active device %v, want the tablet", active)
+	}
+	// Reconnecting to the active device doesn't disconnect it.
+	connect("tablet")
+	if got, want := events.take(), []string{"ConnectByDeviceIdsSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	err := b.DisconnectDevices(bluetoothDirective("DisconnectDevices", `{"devices": [{"uniqueDeviceId": "tablet"}]}`).(*DisconnectDevices))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := events.devices(0); !reflect.DeepEqual(got, []BluetoothDevice{testTablet}) {
+		t.Errorf("got disconnected devices %v, want %v", got, []BluetoothDevice{testTablet})
+	}
+	if got, want := events.take(), []string{"DisconnectDevicesSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	if active := b.BluetoothState().Payload.ActiveDevice; active != nil {
+		t.Errorf("got active device %v after disconnecting, want none", active)
+	}
+	// The phone connects by itself, e.g., from its settings.
+	if err := b.Connected(testPhone); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := events.take(), []string{"ConnectByDeviceIdsSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	if err := b.Disconnected("phone"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := events.take(), []string{"DisconnectDevicesSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
+
+func TestBluetoothConnectByProfile(t *testing.T) {
+	b, adapter, events := newTestBluetooth()
+	pair(t, b, events, `{"uniqueDeviceId": "headset"}, {"uniqueDeviceId": "tablet"}`)
+	connect := func(profile string) error {
+		return b.ConnectByProfile(bluetoothDirective("ConnectByProfile", `{"profile": {"name": "`+profile+`"}}`).(*ConnectByProfile))
+	}
+	if err := connect("A2DP-SOURCE"); err != nil {
+		t.Fatal(err)
+	}
+	if adapter.Connected() != "tablet" {
+		t.Errorf("got %q connected, want the tablet", adapter.Connected())
+	}
+	succeeded, ok := events.events[0].(*ConnectByProfileSucceeded)
+	if !ok || succeeded.Payload.ProfileName != "A2DP-SOURCE" || succeeded.Payload.Device.UniqueDeviceId != "tablet" {
+		t.Errorf("got %#v, want ConnectByProfileSucceeded for the tablet", events.events[0])
+	}
+	events.take()
+	if err := connect("AVRCP"); err == nil {
+		t.Error("got no error for a profile that no paired device supports")
+	}
+	if got, want := events.take(), []string{"ConnectByProfileFailed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	if err := connect("HFP"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := events.take(), []string{"DisconnectDevicesSucceeded", "ConnectByProfileSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
+
+func TestBluetoothDiscoverableMode(t *testing.T) {
+	b, adapter, events := newTestBluetooth()
+	err := b.EnterDiscoverableMode(bluetoothDirective("EnterDiscoverableMode", `{"durationInSeconds": 60}`).(*EnterDiscoverableMode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !adapter.Discoverable() {
+		t.Error("adapter isn't discoverable")
+	}
+	if got, want := events.take(), []string{"EnterDiscoverableModeSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	if err := b.ExitDiscoverableMode(bluetoothDirective("ExitDiscoverableMode", `{}`).(*ExitDiscoverableMode)); err != nil {
+		t.Fatal(err)
+	}
+	if adapter.Discoverable() {
+		t.Error("adapter is still discoverable")
+	}
+	adapter.Err = errors.New("radio is off")
+	err = b.EnterDiscoverableMode(bluetoothDirective("EnterDiscoverableMode", `{"durationInSeconds": 60}`).(*EnterDiscoverableMode))
+	if err != adapter.Err {
+		t.Errorf("got error %v, want %v", err, adapter.Err)
+	}
+	if got, want := events.take(), []string{"EnterDiscoverableModeFailed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
+
+func TestBluetoothMediaControl(t *testing.T) {
+	b, adapter, events := newTestBluetooth()
+	if err := b.MediaControl(bluetoothDirective("Play", `{}`).(*BluetoothMediaControl)); err != errNoActiveBluetoothDevice {
+		t.Errorf("got error %v, want %v", err, errNoActiveBluetoothDevice)
+	}
+	if got, want := events.take(), []string{"MediaControlPlayFailed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	pair(t, b, events, `{"uniqueDeviceId": "phone"}`)
+	if err := b.ConnectByDeviceIds(bluetoothDirective("ConnectByDeviceIds", `{"devices": [{"uniqueDeviceId": "phone"}]}`).(*ConnectByDeviceIds)); err != nil {
+		t.Fatal(err)
+	}
+	events.take()
+	for _, name := range []string{"Play", "Next", "Stop"} {
+		if err := b.MediaControl(bluetoothDirective(name, `{}`).(*BluetoothMediaControl)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := events.take(), []string{"MediaControlPlaySucceeded", "MediaControlNextSucceeded", "MediaControlStopSucceeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	want := []BluetoothMediaCommand{BluetoothMediaCommandPlay, BluetoothMediaCommandNext, BluetoothMediaCommandStop}
+	if got := adapter.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %v, want %v", got, want)
+	}
+	if err := b.SetStreaming(BluetoothStreamingActive); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetStreaming(BluetoothStreamingPaused); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := events.take(), []string{"StreamingStarted", "StreamingEnded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package avs
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultPreRoll is how much audio before the wake word is sent to AVS, as
+// recommended for cloud-based wake word verification.
+const DefaultPreRoll = 500 * time.Millisecond
+
+// ErrCaptureOverrun is returned when captured audio was overwritten before it
+// could be read.
+var ErrCaptureOverrun = errors.New("captured audio is no longer in the buffer")
+
+// CaptureBuffer keeps the most recent audio captured by a microphone in
+// RecognizeAudioFormat, so that a wake word device can send the audio leading
+// up to the wake word along with the user's request.
+//
+// Samples are identified by their index since the buffer was created.
+type CaptureBuffer struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  []byte
+	// The number of bytes written in total.
+	written int64
+	closed  bool
+}
+
+// NewCaptureBuffer returns a buffer which keeps the given duration of audio.
+func NewCaptureBuffer(size time.Duration) *CaptureBuffer {
+	b := &CaptureBuffer{buf: make([]byte, durationToSamples(size)*2)}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Write adds captured audio to the buffer, overwriting the oldest audio.
+func (b *CaptureBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n := len(p)
+	if len(p) > len(b.buf) {
+		b.written += int64(len(p) - len(b.buf))
+		p = p[len(p)-len(b.buf):]
+	}
+	for len(p) > 0 {
+		c := copy(b.buf[b.written%int64(len(b.buf)):], p)
+		b.written += int64(c)
+		p = p[c:]
+	}
+	b.cond.Broadcast()
+	return n, nil
+}
+
+// Close ends the audio. Readers return io.EOF once they have read everything
+// that was written.
+func (b *CaptureBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+	return nil
+}
+
+// Index returns the index of the next sample that will be written.
+func (b *CaptureBuffer) Index() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.written / 2
+}
+
+// Reader returns a reader of the audio starting at the sample index, which
+// blocks until more audio is written. If the sample is no longer in the
+// buffer, the reader starts at the oldest sample that is.
+func (b *CaptureBuffer) Reader(index int64) io.ReadCloser {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &captureBufferReader{b: b, pos: b.clamp(index) * 2}
+}
+
+// WakeWordReader returns a reader of the audio starting preRoll before the
+// wake word that was detected between the start and end sample indices, and
+// the initiator with the indices of the wake word in that audio. If the start
+// of the wake word is no longer in the buffer, the indices are clamped to the
+// oldest sample that is.
+func (b *CaptureBuffer) WakeWordReader(wakeWord string, start, end int64, preRoll time.Duration) (io.ReadCloser, *RecognizeInitiator) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	first := b.clamp(start - durationToSamples(preRoll))
+	if start < first {
+		start = first
+	}
+	if end < start {
+		end = start
+	}
+	r := &captureBufferReader{b: b, pos: first * 2}
+	return r, NewWakeWordInitiator(wakeWord, start-first, end-first)
+}
+
+// Returns the index of the sample closest to index that is in the buffer.
+//
+// This method must be called with b.mu held.
+func (b *CaptureBuffer) clamp(index int64) int64 {
+	oldest := (b.written - int64(len(b.buf))) / 2
+	if index < oldest {
+		index = oldest
+	}
+	if index < 0 {
+		index = 0
+	}
+	if latest := b.written / 2; index > latest {
+		index = latest
+	}
+	return index
+}
+
+// Reads audio from a CaptureBuffer as it's written.
+type captureBufferReader struct {
+	b *CaptureBuffer
+	// The byte offset of the next byte to read.
+	pos    int64
+	closed bool
+}
+
+func (r *captureBufferReader) Read(p []byte) (int, error) {
+	b := r.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for r.pos == b.written && !b.closed && !r.closed {
+		b.cond.Wait()
+	}
+	if r.closed || r.pos == b.written {
+		return 0, io.EOF
+	}
+	if r.pos < b.written-int64(len(b.buf)) {
+		return 0, ErrCaptureOverrun
+	}
+	if available := b.written - r.pos; int64(len(p)) > available {
+		p = p[:available]
+	}
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], b.buf[(r.pos+int64(n))%int64(len(b.buf)):])
+		n += c
+	}
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *captureBufferReader) Close() error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	r.closed = true
+	r.b.cond.Broadcast()
+	return nil
+}
+
+// Returns the number of samples of RecognizeAudioFormat audio in the duration.
+func durationToSamples(d time.Duration) int64 {
+	return int64(d) * int64(RecognizeAudioFormat.SampleRate) / int64(time.Second)
+}
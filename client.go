@@ -2,14 +2,26 @@ package avs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// ErrAuthorizationRevoked is returned for requests made with an access token
+// whose authorization was revoked by the user (see RevokeAuthorization).
+var ErrAuthorizationRevoked = errors.New("authorization was revoked")
+
+// How long a Client remembers that an access token was revoked. Access tokens
+// expire well before this, so a revoked token can't be used again once it's
+// forgotten.
+const revokedTokenTTL = 24 * time.Hour
+
 // Multipart object returned by AVS.
 type responsePart struct {
 	Directive *Message
@@ -17,13 +29,187 @@ type responsePart struct {
 
 // Client enables making requests and creating downchannels to AVS.
 type Client struct {
+	// EndpointURL is the base URL of AVS. It must not be changed directly
+	// once the client is in use; use SetEndpointURL instead.
 	EndpointURL string
+	// AudioConverter is optional. If it's set (e.g., to ConvertAudio), it's
+	// used to convert WAV audio that isn't in the format required by AVS (see
+	// RecognizeAudio).
+	AudioConverter AudioConverter
+	// OnAuthorizationRevoked is optional. It's called with the access token
+	// when AVS sends a RevokeAuthorization directive for it, e.g., so that the
+	// user's session can be ended.
+	OnAuthorizationRevoked func(accessToken string)
+
+	mu               sync.Mutex
+	contextProviders []ContextProvider
+	// When the authorization of each access token was revoked.
+	revoked map[string]time.Time
+	// The bodies of the open downchannels of each access token.
+	downchannels map[string][]io.Closer
+}
+
+// SetEndpointURL changes the base URL of AVS for all further requests, e.g.,
+// for the SetEndpoint directive. Downchannels that are already open stay
+// connected to the old endpoint.
+func (c *Client) SetEndpointURL(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.EndpointURL = url
+}
+
+// Returns the current base URL of AVS.
+func (c *Client) endpointURL() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.EndpointURL
+}
+
+// RevokeAuthorization closes the downchannels of the access token and makes
+// all further requests with it fail with ErrAuthorizationRevoked. It's called
+// automatically when AVS sends the RevokeAuthorization directive.
+func (c *Client) RevokeAuthorization(accessToken string) {
+	c.mu.Lock()
+	if c.revokedLocked(accessToken) {
+		c.mu.Unlock()
+		return
+	}
+	if c.revoked == nil {
+		c.revoked = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for token, at := range c.revoked {
+		if now.Sub(at) >= revokedTokenTTL {
+			delete(c.revoked, token)
+		}
+	}
+	c.revoked[accessToken] = now
+	downchannels := c.downchannels[accessToken]
+	delete(c.downchannels, accessToken)
+	c.mu.Unlock()
+	for _, body := range downchannels {
+		body.Close()
+	}
+	if c.OnAuthorizationRevoked != nil {
+		c.OnAuthorizationRevoked(accessToken)
+	}
+}
+
+// AuthorizationRevoked returns whether the authorization of the access token
+// has been revoked.
+func (c *Client) AuthorizationRevoked(accessToken string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.revokedLocked(accessToken)
+}
+
+// Like AuthorizationRevoked, but c.mu must be held. Forgets the access token
+// if it was revoked long enough ago.
+func (c *Client) revokedLocked(accessToken string) bool {
+	at, ok := c.revoked[accessToken]
+	if !ok {
+		return false
+	}
+	if time.Since(at) >= revokedTokenTTL {
+		delete(c.revoked, accessToken)
+		return false
+	}
+	return true
+}
+
+// Revokes the authorization of the access token if the directive is
+// RevokeAuthorization.
+func (c *Client) checkRevoked(accessToken string, directive *Message) {
+	if directive.String() == "System.RevokeAuthorization" {
+		c.RevokeAuthorization(accessToken)
+	}
+}
+
+// Keeps track of a downchannel so that it can be closed if the authorization
+// of its access token is revoked. Fails if the authorization was revoked while
+// the downchannel was being opened.
+func (c *Client) addDownchannel(accessToken string, body io.Closer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.revokedLocked(accessToken) {
+		return ErrAuthorizationRevoked
+	}
+	if c.downchannels == nil {
+		c.downchannels = make(map[string][]io.Closer)
+	}
+	c.downchannels[accessToken] = append(c.downchannels[accessToken], body)
+	return nil
+}
+
+func (c *Client) removeDownchannel(accessToken string, body io.Closer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bodies := c.downchannels[accessToken]
+	for i, b := range bodies {
+		if b == body {
+			c.downchannels[accessToken] = append(bodies[:i], bodies[i+1:]...)
+			break
+		}
+	}
+	if len(c.downchannels[accessToken]) == 0 {
+		delete(c.downchannels, accessToken)
+	}
+}
+
+// AddContextProvider registers a provider of context which Do will attach to
+// the events that require it (see RequiresContext).
+func (c *Client) AddContextProvider(provider ContextProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.contextProviders = append(c.contextProviders, provider)
+}
+
+// Context returns the current context from all the registered providers.
+func (c *Client) Context() []TypedMessage {
+	c.mu.Lock()
+	providers := c.contextProviders
+	c.mu.Unlock()
+	context := make([]TypedMessage, 0, len(providers))
+	for _, provider := range providers {
+		context = append(context, provider.Context())
+	}
+	return context
+}
+
+// Returns a copy of the request with the current context of the registered
+// providers added, unless the request already has a context of the same name.
+func (c *Client) withContext(request *Request) *Request {
+	if request.OmitContext || request.Event == nil || !RequiresContext(request.Event) {
+		return request
+	}
+	names := make(map[string]bool)
+	for _, m := range request.Context {
+		names[m.GetMessage().String()] = true
+	}
+	r := *request
+	r.Context = append([]TypedMessage{}, request.Context...)
+	for _, m := range c.Context() {
+		if !names[m.GetMessage().String()] {
+			r.Context = append(r.Context, m)
+		}
+	}
+	return &r
 }
 
 // CreateDownchannel establishes a persistent connection with AVS and returns a
 // read-only channel through which AVS will deliver directives.
 func (c *Client) CreateDownchannel(accessToken string) (<-chan *Message, error) {
-	req, err := http.NewRequest("GET", c.EndpointURL + DirectivesPath, nil)
+	return c.OpenDownchannel(accessToken, nil)
+}
+
+// OpenDownchannel is like CreateDownchannel, but the downchannel is closed
+// when done is closed. The directives channel is closed once the connection
+// has been closed, and directives that weren't received by then are dropped.
+func (c *Client) OpenDownchannel(accessToken string, done <-chan struct{}) (<-chan *Message, error) {
+	if c.AuthorizationRevoked(accessToken) {
+		return nil, ErrAuthorizationRevoked
+	}
+	req, err := http.NewRequest("GET", c.endpointURL()+DirectivesPath, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -36,10 +222,25 @@ func (c *Client) CreateDownchannel(accessToken string) (<-chan *Message, error)
 		resp.Body.Close()
 		return nil, err
 	}
+	if err := c.addDownchannel(accessToken, resp.Body); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
 	directives := make(chan *Message)
+	finished := make(chan struct{})
+	go func() {
+		// Closing the body makes the reader below fail.
+		select {
+		case <-done:
+			resp.Body.Close()
+		case <-finished:
+		}
+	}()
 	go func() {
 		defer close(directives)
+		defer close(finished)
 		defer resp.Body.Close()
+		defer c.removeDownchannel(accessToken, resp.Body)
 		mr, err := newMultipartReaderFromResponse(resp)
 		if err != nil {
 			return
@@ -59,14 +260,40 @@ func (c *Client) CreateDownchannel(accessToken string) (<-chan *Message, error)
 			if err != nil {
 				break
 			}
-			directives <- response.Directive
+			select {
+			case directives <- response.Directive:
+			case <-done:
+				return
+			}
+			c.checkRevoked(accessToken, response.Directive)
 		}
 	}()
 	return directives, nil
 }
 
 // Do posts a request to the AVS service's /events endpoint.
+//
+// If the event requires context, the context of all registered context
+// providers is added to the request. If the audio of the request is a WAV
+// file, its header is removed before it's sent (see RecognizeAudio), and Opus
+// audio in an Ogg file is extracted from it (see OpusAudio).
 func (c *Client) Do(request *Request) (*Response, error) {
+	if c.AuthorizationRevoked(request.AccessToken) {
+		return nil, ErrAuthorizationRevoked
+	}
+	request, format := c.withContext(request).withAudioFormat()
+	var audio io.Reader
+	if request.Audio != nil {
+		var err error
+		if format == RecognizeFormatOpus {
+			audio, err = OpusAudio(request.Audio)
+		} else {
+			audio, err = RecognizeAudio(request.Audio, c.AudioConverter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 	body, bodyIn := io.Pipe()
 	writer := multipart.NewWriter(bodyIn)
 	go func() {
@@ -76,14 +303,14 @@ func (c *Client) Do(request *Request) (*Response, error) {
 			bodyIn.CloseWithError(err)
 			return
 		}
-		if request.Audio != nil {
-			p, err := writer.CreateFormFile("audio", "audio.wav")
+		if audio != nil {
+			p, err := createAudioPart(writer, format)
 			if err != nil {
 				bodyIn.CloseWithError(err)
 				return
 			}
 			// Run io.Copy in goroutine so audio can be streamed
-			_, err = io.Copy(p, request.Audio)
+			_, err = io.Copy(p, audio)
 			if err != nil {
 				bodyIn.CloseWithError(err)
 				return
@@ -97,7 +324,7 @@ func (c *Client) Do(request *Request) (*Response, error) {
 		bodyIn.Close()
 	}()
 	// Send the request to AVS.
-	req, err := http.NewRequest("POST", c.EndpointURL + EventsPath, body)
+	req, err := http.NewRequest("POST", c.endpointURL()+EventsPath, body)
 	if err != nil {
 		return nil, err
 	}
@@ -157,6 +384,7 @@ func (c *Client) Do(request *Request) (*Response, error) {
 				return nil, fmt.Errorf("missing directive %s", string(data))
 			}
 			response.Directives = append(response.Directives, resp.Directive)
+			c.checkRevoked(request.AccessToken, resp.Directive)
 		} else {
 			return nil, fmt.Errorf("unhandled part %s", p)
 		}
@@ -167,8 +395,11 @@ func (c *Client) Do(request *Request) (*Response, error) {
 // Ping will ping AVS on behalf of a user to indicate that the connection is
 // still alive.
 func (c *Client) Ping(accessToken string) error {
+	if c.AuthorizationRevoked(accessToken) {
+		return ErrAuthorizationRevoked
+	}
 	// TODO: Once Go supports sending PING frames, that would be a better alternative.
-	req, err := http.NewRequest("GET", c.EndpointURL + PingPath, nil)
+	req, err := http.NewRequest("GET", c.endpointURL()+PingPath, nil)
 	if err != nil {
 		return err
 	}
@@ -4,6 +4,41 @@ import (
 	"time"
 )
 
+// ContextProvider supplies the current value of a context, e.g., the
+// VolumeState of the device's speaker. Context providers can be registered
+// with a Client so that the context is attached to the events that require it.
+type ContextProvider interface {
+	Context() TypedMessage
+}
+
+// The ContextProviderFunc type is an adapter to allow the use of ordinary
+// functions as context providers.
+type ContextProviderFunc func() TypedMessage
+
+// Context calls f().
+func (f ContextProviderFunc) Context() TypedMessage {
+	return f()
+}
+
+// The events which AVS expects to be sent with the context of all the
+// components of the device.
+var contextEvents = map[string]bool{
+	"PlaybackController.ButtonCommandIssued":   true,
+	"PlaybackController.NextCommandIssued":     true,
+	"PlaybackController.PauseCommandIssued":    true,
+	"PlaybackController.PlayCommandIssued":     true,
+	"PlaybackController.PreviousCommandIssued": true,
+	"PlaybackController.ToggleCommandIssued":   true,
+	"SpeechRecognizer.Recognize":               true,
+	"System.SynchronizeState":                  true,
+}
+
+// RequiresContext returns whether AVS expects the event to be sent with the
+// context of the device.
+func RequiresContext(event TypedMessage) bool {
+	return contextEvents[event.GetMessage().String()]
+}
+
 // newContext creates a Message suited for being used as a context value.
 func newContext(namespace, name string) *Message {
 	return &Message{
@@ -51,6 +86,77 @@ func NewPlaybackState(token string, offset time.Duration, activity PlayerActivit
 	return m
 }
 
+/********** Bluetooth **********/
+
+// The BluetoothState context.
+type BluetoothState struct {
+	*Message
+	Payload struct {
+		AlexaDevice struct {
+			FriendlyName string `json:"friendlyName"`
+		} `json:"alexaDevice"`
+		PairedDevices []BluetoothDevice `json:"pairedDevices"`
+		// The device that is connected, if any.
+		ActiveDevice *BluetoothActiveDevice `json:"activeDevice,omitempty"`
+	} `json:"payload"`
+}
+
+// BluetoothActiveDevice is the connected device in the BluetoothState context.
+type BluetoothActiveDevice struct {
+	BluetoothDevice
+	Streaming BluetoothStreaming `json:"streaming"`
+}
+
+func NewBluetoothState(friendlyName string, pairedDevices []BluetoothDevice, activeDevice *BluetoothActiveDevice) *BluetoothState {
+	m := new(BluetoothState)
+	m.Message = newContext("Bluetooth", "BluetoothState")
+	m.Payload.AlexaDevice.FriendlyName = friendlyName
+	m.Payload.PairedDevices = pairedDevices
+	m.Payload.ActiveDevice = activeDevice
+	return m
+}
+
+/********** EqualizerController **********/
+
+// Also used by the EqualizerChanged event.
+type equalizerState struct {
+	Bands []EqualizerBandLevel `json:"bands"`
+	Mode  EqualizerMode        `json:"mode,omitempty"`
+}
+
+// The EqualizerState context.
+type EqualizerState struct {
+	*Message
+	Payload equalizerState `json:"payload"`
+}
+
+func NewEqualizerState(bands []EqualizerBandLevel, mode EqualizerMode) *EqualizerState {
+	m := new(EqualizerState)
+	m.Message = newContext("EqualizerController", "EqualizerState")
+	m.Payload.Bands = bands
+	m.Payload.Mode = mode
+	return m
+}
+
+/********** Notifications **********/
+
+// The IndicatorState context.
+type IndicatorState struct {
+	*Message
+	Payload struct {
+		IsEnabled                  bool `json:"isEnabled"`
+		IsVisualIndicatorPersisted bool `json:"isVisualIndicatorPersisted"`
+	} `json:"payload"`
+}
+
+func NewIndicatorState(isEnabled, isVisualIndicatorPersisted bool) *IndicatorState {
+	m := new(IndicatorState)
+	m.Message = newContext("Notifications", "IndicatorState")
+	m.Payload.IsEnabled = isEnabled
+	m.Payload.IsVisualIndicatorPersisted = isVisualIndicatorPersisted
+	return m
+}
+
 /********** Speaker **********/
 
 // The VolumeState context.
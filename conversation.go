@@ -0,0 +1,193 @@
+package avs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Conversation runs a multi-turn dialog with AVS: it sends the user's speech
+// in a Recognize event, plays the Speak directives in the response and, as
+// long as AVS responds with ExpectSpeech, listens to the user again.
+//
+// Use a Device instead for a complete client which also handles directives
+// that arrive on the downchannel.
+type Conversation struct {
+	Client      *Client
+	AccessToken string
+	// NewAudio opens a new stream of the user's speech for every turn.
+	NewAudio AudioSource
+	Profile  RecognizeProfile
+	// The format of the audio opened by NewAudio. If it's empty,
+	// RecognizeFormatL16 is used. Silence can only be detected in PCM audio,
+	// so with RecognizeFormatOpus, ExpectSpeech timeouts aren't handled.
+	Format RecognizeFormat
+	// Initiator is optional. It's sent with the first Recognize event.
+	Initiator *RecognizeInitiator
+	// Speech is optional. If it's set, it plays Speak directives.
+	Speech *SpeechSynthesizer
+	// Handler is optional. It's called with the directives that the
+	// Conversation doesn't handle itself.
+	Handler func(directive TypedMessage, response *Response) error
+	// Log is optional. A line is written to it for every event that is sent and
+	// every directive that is received.
+	Log io.Writer
+}
+
+// NewConversation returns a Conversation which sends requests with the access
+// token and opens the user's speech with newAudio.
+func NewConversation(client *Client, accessToken string, newAudio AudioSource) *Conversation {
+	return &Conversation{
+		Client:      client,
+		AccessToken: accessToken,
+		NewAudio:    newAudio,
+		Profile:     RecognizeProfileCloseTalk,
+	}
+}
+
+// Run starts the conversation and blocks until the dialog has ended, either
+// because AVS stopped expecting speech or because the user didn't speak
+// before an ExpectSpeech directive timed out (in which case an
+// ExpectSpeechTimedOut event is sent).
+func (c *Conversation) Run() error {
+	// ExpectSpeech initiators are sent back as raw JSON, so the initiator of
+	// the first Recognize event is encoded the same way.
+	initiator := c.Initiator.raw()
+	var expect *ExpectSpeech
+	for {
+		audio, err := c.NewAudio()
+		if err != nil {
+			return err
+		}
+		var speech io.Reader = audio
+		if c.Format != RecognizeFormatOpus {
+			speech, err = RecognizeAudio(audio, c.Client.AudioConverter)
+			if err != nil {
+				audio.Close()
+				return err
+			}
+			if expect != nil {
+				var spoke bool
+				speech, spoke, err = waitForSpeech(speech, expect.Timeout())
+				if err != nil {
+					audio.Close()
+					return err
+				}
+				if !spoke {
+					audio.Close()
+					c.logf("user didn't speak within %s", expect.Timeout())
+					_, err := c.send(NewRequest(c.AccessToken), NewExpectSpeechTimedOut(RandomUUIDString()))
+					return err
+				}
+			}
+			if c.Profile == RecognizeProfileNearField || c.Profile == RecognizeProfileFarField {
+				speech = NewEndpointer(speech, EndpointerConfig{})
+			}
+		}
+		// Each turn is a new dialog, which the speech synthesizer has to know
+		// about so that it doesn't drop the directives of the response.
+		dialogRequestId := RandomUUIDString()
+		if c.Speech != nil {
+			if err := c.Speech.Interrupt(dialogRequestId); err != nil {
+				audio.Close()
+				return err
+			}
+		}
+		request := NewRequest(c.AccessToken)
+		request.Audio = speech
+		request.Format = c.Format
+		response, err := c.send(request, NewRecognizeWithRawInitiator(RandomUUIDString(), dialogRequestId, c.Profile, c.Format, initiator))
+		audio.Close()
+		if err != nil {
+			return err
+		}
+		if expect, err = c.handle(response); err != nil {
+			return err
+		}
+		if expect == nil {
+			return nil
+		}
+		initiator = expect.Payload.Initiator
+	}
+}
+
+// Handles the directives in the response in order and returns the
+// ExpectSpeech directive, if the dialog should continue.
+func (c *Conversation) handle(response *Response) (*ExpectSpeech, error) {
+	var expect *ExpectSpeech
+	for _, directive := range response.Directives {
+		c.logf("< %s", describeMessage(directive))
+		switch d := directive.Typed().(type) {
+		case *Speak:
+			if c.Speech == nil {
+				continue
+			}
+			if err := c.Speech.Speak(d, response); err != nil {
+				return nil, err
+			}
+		case *ExpectSpeech:
+			if c.Speech != nil && !c.Speech.ExpectSpeech(d) {
+				continue
+			}
+			expect = d
+		case *StopCapture:
+			// The audio has already been sent.
+		default:
+			if c.Handler == nil {
+				continue
+			}
+			if err := c.Handler(d, response); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return expect, nil
+}
+
+// Sends the event in the request and logs it.
+func (c *Conversation) send(request *Request, event TypedMessage) (*Response, error) {
+	request.Event = event
+	c.logf("> %s", describeMessage(event.GetMessage()))
+	return c.Client.Do(request)
+}
+
+func (c *Conversation) logf(format string, args ...interface{}) {
+	if c.Log != nil {
+		fmt.Fprintf(c.Log, "%s %s\n", time.Now().Format("15:04:05.000"), fmt.Sprintf(format, args...))
+	}
+}
+
+// Returns the name of the message and its dialog request id, if any.
+func describeMessage(m *Message) string {
+	if id := m.Header["dialogRequestId"]; id != "" {
+		return fmt.Sprintf("%s (dialog %s)", m, id)
+	}
+	return m.String()
+}
+
+// Reads PCM audio until the user starts speaking or the timeout (measured in
+// audio) has passed. It returns the whole audio, including what was read, and
+// whether the user spoke.
+func waitForSpeech(audio io.Reader, timeout time.Duration) (io.Reader, bool, error) {
+	detector := NewEndpointer(nil, EndpointerConfig{
+		MaxLength:       timeout,
+		NoSpeechTimeout: timeout,
+	})
+	var read bytes.Buffer
+	frame := make([]byte, detector.frameSamples()*2)
+	for !detector.Speaking() {
+		if detector.Reason() != EndpointReasonNone {
+			return nil, false, nil
+		}
+		n, err := io.ReadFull(audio, frame)
+		read.Write(frame[:n])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		detector.analyze(frame)
+	}
+	return io.MultiReader(&read, audio), true, nil
+}
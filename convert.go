@@ -0,0 +1,266 @@
+package avs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// The number of input samples on each side of an output sample that the
+// resampling filter takes into account (at the input sample rate, before
+// widening the filter for downsampling).
+const resampleHalfTaps = 16
+
+// The number of filter coefficients computed per input sample. Coefficients
+// between these points are linearly interpolated.
+const resampleTableResolution = 256
+
+// The shape parameter of the Kaiser window applied to the resampling filter.
+// Higher values give more stopband attenuation at the cost of a wider
+// transition band.
+const resampleKaiserBeta = 8.0
+
+// ConvertAudio is an AudioConverter which converts PCM audio of any sample
+// rate and number of channels to RecognizeAudioFormat. Samples may be 16, 24
+// or 32-bit signed integers or 32-bit floats, in little-endian byte order.
+//
+// Channels are mixed down to mono and the audio is resampled with a windowed
+// sinc filter. Conversion happens as the returned reader is read, so it's
+// suitable for audio that is being streamed.
+func ConvertAudio(audio io.Reader, format AudioFormat) (io.Reader, error) {
+	decode := sampleDecoder(format)
+	if decode == nil || format.Channels < 1 || format.SampleRate < 1 {
+		return nil, &AudioFormatError{format}
+	}
+	var samples sampleReader = &monoReader{
+		r:         bufio.NewReader(audio),
+		channels:  format.Channels,
+		frameSize: format.Channels * format.BitsPerSample / 8,
+		decode:    decode,
+	}
+	if format.SampleRate != RecognizeAudioFormat.SampleRate {
+		samples = newResampler(samples, format.SampleRate, RecognizeAudioFormat.SampleRate)
+	}
+	return &pcm16Writer{samples: samples}, nil
+}
+
+// Returns a function which converts a single sample to a float in [-1, 1], or
+// nil if the format isn't supported.
+func sampleDecoder(format AudioFormat) func(b []byte) float64 {
+	switch {
+	case format.Float && format.BitsPerSample == 32:
+		return func(b []byte) float64 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}
+	case format.Float:
+		return nil
+	case format.BitsPerSample == 16:
+		return func(b []byte) float64 {
+			return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+		}
+	case format.BitsPerSample == 24:
+		return func(b []byte) float64 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			return float64(v) / (1 << 23)
+		}
+	case format.BitsPerSample == 32:
+		return func(b []byte) float64 {
+			return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+		}
+	}
+	return nil
+}
+
+// A stream of mono samples.
+type sampleReader interface {
+	// ReadSamples fills p with samples and returns how many were read. At the
+	// end of the stream, it returns io.EOF.
+	ReadSamples(p []float64) (int, error)
+}
+
+// Decodes frames of interleaved samples and mixes their channels down to mono.
+type monoReader struct {
+	r         io.Reader
+	channels  int
+	frameSize int
+	decode    func(b []byte) float64
+	buf       []byte
+}
+
+func (m *monoReader) ReadSamples(p []float64) (int, error) {
+	if size := len(p) * m.frameSize; len(m.buf) < size {
+		m.buf = make([]byte, size)
+	}
+	n, err := io.ReadFull(m.r, m.buf[:len(p)*m.frameSize])
+	if err == io.ErrUnexpectedEOF {
+		// Ignore any trailing partial frame.
+		err = nil
+	}
+	frames := n / m.frameSize
+	if frames == 0 && err == nil {
+		err = io.EOF
+	}
+	sampleSize := m.frameSize / m.channels
+	for i := 0; i < frames; i++ {
+		frame := m.buf[i*m.frameSize:]
+		var sum float64
+		for c := 0; c < m.channels; c++ {
+			sum += m.decode(frame[c*sampleSize:])
+		}
+		p[i] = sum / float64(m.channels)
+	}
+	return frames, err
+}
+
+// Converts a stream of samples from one sample rate to another with a
+// Kaiser-windowed sinc filter.
+type resampler struct {
+	in              sampleReader
+	inRate, outRate int64
+	// The width of the filter on each side, in input samples.
+	halfWidth int
+	// Coefficients of one side of the filter, sampled resampleTableResolution
+	// times per input sample.
+	table []float64
+	// Buffered input samples; buf[0] is input sample number bufStart.
+	buf      []float64
+	bufStart int64
+	// The number of input samples read so far, and whether that's all of them.
+	read int64
+	eof  bool
+	// The number of output samples produced so far.
+	n int64
+}
+
+func newResampler(in sampleReader, inRate, outRate int) *resampler {
+	r := &resampler{in: in, inRate: int64(inRate), outRate: int64(outRate)}
+	// When downsampling, the cutoff must be lowered to the new Nyquist
+	// frequency (with some margin for the transition band) to avoid aliasing.
+	cutoff := 1.0
+	if outRate < inRate {
+		cutoff = 0.95 * float64(outRate) / float64(inRate)
+	}
+	r.halfWidth = int(math.Ceil(resampleHalfTaps / cutoff))
+	r.table = make([]float64, r.halfWidth*resampleTableResolution+2)
+	i0beta := besselI0(resampleKaiserBeta)
+	for i := range r.table {
+		t := float64(i) / resampleTableResolution
+		if t > float64(r.halfWidth) {
+			break
+		}
+		x := t / float64(r.halfWidth)
+		window := besselI0(resampleKaiserBeta*math.Sqrt(1-x*x)) / i0beta
+		r.table[i] = cutoff * sinc(cutoff*t) * window
+	}
+	return r
+}
+
+func (r *resampler) ReadSamples(p []float64) (int, error) {
+	for i := range p {
+		// The position of the output sample in the input, as an integer part
+		// and a fraction.
+		pos := r.n * r.inRate
+		center := pos / r.outRate
+		frac := float64(pos%r.outRate) / float64(r.outRate)
+		if err := r.fill(center + int64(r.halfWidth)); err != nil {
+			return i, err
+		}
+		if r.eof && center >= r.read {
+			if i == 0 {
+				return 0, io.EOF
+			}
+			return i, nil
+		}
+		var sum float64
+		for k := center - int64(r.halfWidth) + 1; k <= center+int64(r.halfWidth); k++ {
+			if k < r.bufStart || k >= r.read {
+				continue
+			}
+			sum += r.buf[k-r.bufStart] * r.coefficient(float64(k-center)-frac)
+		}
+		p[i] = sum
+		r.n++
+		// Drop input samples that no future output sample needs.
+		if drop := center - int64(r.halfWidth) - r.bufStart; drop >= 1024 {
+			r.buf = r.buf[:copy(r.buf, r.buf[drop:])]
+			r.bufStart += drop
+		}
+	}
+	return len(p), nil
+}
+
+// Reads input samples until sample number last is buffered or the input ends.
+func (r *resampler) fill(last int64) error {
+	var chunk [512]float64
+	for !r.eof && r.read <= last {
+		n, err := r.in.ReadSamples(chunk[:])
+		r.buf = append(r.buf, chunk[:n]...)
+		r.read += int64(n)
+		if err == io.EOF {
+			r.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the filter coefficient at t input samples from the center.
+func (r *resampler) coefficient(t float64) float64 {
+	t = math.Abs(t) * resampleTableResolution
+	i := int(t)
+	if i >= len(r.table)-1 {
+		return 0
+	}
+	f := t - float64(i)
+	return r.table[i]*(1-f) + r.table[i+1]*f
+}
+
+// Writes a stream of samples as 16-bit little-endian PCM.
+type pcm16Writer struct {
+	samples sampleReader
+	buf     []float64
+	out     []byte
+	err     error
+}
+
+func (w *pcm16Writer) Read(p []byte) (int, error) {
+	for len(w.out) == 0 {
+		if w.err != nil {
+			return 0, w.err
+		}
+		if w.buf == nil {
+			w.buf = make([]float64, 1024)
+		}
+		n, err := w.samples.ReadSamples(w.buf)
+		w.err = err
+		for _, v := range w.buf[:n] {
+			s := int16(math.Max(-32768, math.Min(32767, math.Floor(v*32768+0.5))))
+			w.out = append(w.out, byte(s), byte(s>>8))
+		}
+	}
+	n := copy(p, w.out)
+	w.out = w.out[n:]
+	return n, nil
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// The zeroth order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < 1e-12*sum {
+			break
+		}
+	}
+	return sum
+}
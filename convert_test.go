@@ -0,0 +1,255 @@
+package avs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// Returns n frames of 16-bit PCM where channel c of frame i is f(c, t) at
+// time t = i/rate.
+func synthesize(rate, channels, n int, f func(c int, t float64) float64) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		t := float64(i) / float64(rate)
+		for c := 0; c < channels; c++ {
+			binary.Write(&b, binary.LittleEndian, int16(math.Floor(f(c, t)*32767+0.5)))
+		}
+	}
+	return b.Bytes()
+}
+
+// A linear sine sweep from f0 to f1 Hz over the duration d (in seconds).
+func sweep(f0, f1, d float64) func(t float64) float64 {
+	return func(t float64) float64 {
+		return 0.5 * math.Sin(2*math.Pi*(f0*t+(f1-f0)*t*t/(2*d)))
+	}
+}
+
+func convert(t *testing.T, audio []byte, format AudioFormat) []float64 {
+	r, err := ConvertAudio(bytes.NewReader(audio), format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]float64, len(data)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(data[2*i:]))) / 32768
+	}
+	return samples
+}
+
+// Returns the signal to noise ratio, in dB, of the output compared to the
+// expected signal (at 16 kHz). The edges, where the filter has no input on
+// one side, are ignored.
+func snr(output []float64, expected func(t float64) float64) float64 {
+	var signal, noise float64
+	for i := 100; i < len(output)-100; i++ {
+		e := expected(float64(i) / 16000)
+		d := output[i] - e
+		signal += e * e
+		noise += d * d
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+func TestConvertAudioLength(t *testing.T) {
+	tests := []struct {
+		rate, frames, want int
+	}{
+		{44100, 44100, 16000},
+		{44100, 44101, 16001},
+		{44100, 441, 160},
+		{44100, 442, 161},
+		{48000, 48000, 16000},
+		{48000, 48001, 16001},
+		{48000, 3, 1},
+		{16000, 1234, 1234},
+		{8000, 1000, 2000},
+	}
+	for _, test := range tests {
+		format := AudioFormat{SampleRate: test.rate, Channels: 2, BitsPerSample: 16}
+		audio := synthesize(test.rate, 2, test.frames, func(c int, t float64) float64 { return 0 })
+		if n := len(convert(t, audio, format)); n != test.want {
+			t.Errorf("%d frames at %d Hz: got %d samples, want %d", test.frames, test.rate, n, test.want)
+		}
+	}
+}
+
+func TestConvertAudioSweep(t *testing.T) {
+	// The sweep stays within the passband of the resampling filter, which
+	// starts rolling off a little below 7.6 kHz.
+	s := sweep(50, 6500, 2)
+	for _, rate := range []int{44100, 48000} {
+		format := AudioFormat{SampleRate: rate, Channels: 1, BitsPerSample: 16}
+		audio := synthesize(rate, 1, 2*rate, func(c int, t float64) float64 { return s(t) })
+		if got := snr(convert(t, audio, format), s); got < 75 {
+			t.Errorf("%d Hz: SNR %.1f dB, want at least 75 dB", rate, got)
+		}
+	}
+}
+
+func TestConvertAudioAliasing(t *testing.T) {
+	// A sweep above the Nyquist frequency of the output must be filtered out
+	// rather than folded back into the audible range.
+	for _, rate := range []int{44100, 48000} {
+		s := sweep(8500, float64(rate)/2-500, 1)
+		format := AudioFormat{SampleRate: rate, Channels: 1, BitsPerSample: 16}
+		audio := synthesize(rate, 1, rate, func(c int, t float64) float64 { return s(t) })
+		output := convert(t, audio, format)
+		var energy float64
+		for _, v := range output[100 : len(output)-100] {
+			energy += v * v
+		}
+		// Relative to the power of the input sweep (0.5² / 2).
+		level := 10 * math.Log10(energy/float64(len(output)-200)/0.125)
+		if level > -50 {
+			t.Errorf("%d Hz: aliasing at %.1f dB, want at most -50 dB", rate, level)
+		}
+	}
+}
+
+func TestConvertAudioDownmix(t *testing.T) {
+	s := sweep(50, 7000, 1)
+	mono := AudioFormat{SampleRate: 48000, Channels: 1, BitsPerSample: 16}
+	stereo := AudioFormat{SampleRate: 48000, Channels: 2, BitsPerSample: 16}
+	// The same signal on both channels is the same as the mono signal.
+	want := convert(t, synthesize(48000, 1, 48000, func(c int, t float64) float64 { return s(t) }), mono)
+	got := convert(t, synthesize(48000, 2, 48000, func(c int, t float64) float64 { return s(t) }), stereo)
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+	// Opposite signals cancel out.
+	silent := convert(t, synthesize(48000, 2, 48000, func(c int, t float64) float64 {
+		if c == 1 {
+			return -s(t)
+		}
+		return s(t)
+	}), stereo)
+	for i, v := range silent {
+		if math.Abs(v) > 2.0/32768 {
+			t.Fatalf("sample %d: got %v, want silence", i, v)
+		}
+	}
+}
+
+func TestConvertAudioChunked(t *testing.T) {
+	s := sweep(50, 7000, 1)
+	for _, rate := range []int{44100, 48000} {
+		format := AudioFormat{SampleRate: rate, Channels: 2, BitsPerSample: 16}
+		audio := synthesize(rate, 2, rate/2, func(c int, t float64) float64 { return s(t) })
+		r, err := ConvertAudio(bytes.NewReader(audio), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		oneShot, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Feed the input one byte at a time, and read the output the same way.
+		r, err = ConvertAudio(iotest.OneByteReader(bytes.NewReader(audio)), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunked, err := ioutil.ReadAll(iotest.OneByteReader(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(chunked, oneShot) {
+			t.Errorf("%d Hz: chunked output (%d bytes) differs from one-shot output (%d bytes)", rate, len(chunked), len(oneShot))
+		}
+	}
+}
+
+// Returns the little-endian bytes of 32-bit floats.
+func float32s(values ...float32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return b
+}
+
+func TestConvertAudioSampleFormats(t *testing.T) {
+	pcm24 := AudioFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 24}
+	pcm32 := AudioFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 32}
+	float := AudioFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 32, Float: true}
+	tests := []struct {
+		name   string
+		format AudioFormat
+		audio  []byte
+		want   []int16
+	}{
+		{"16-bit", RecognizeAudioFormat, []byte{0x00, 0x80, 0xff, 0xff, 0x01, 0x00, 0xff, 0x7f}, []int16{-32768, -1, 1, 32767}},
+		// Negative 24-bit samples are sign extended.
+		{"24-bit", pcm24, []byte{
+			0x00, 0x00, 0x80, // -8388608
+			0x00, 0x80, 0xff, // -32768
+			0x00, 0xff, 0xff, // -256
+			0x00, 0x01, 0x00, // 256
+			0x00, 0x80, 0x00, // 32768
+			0xff, 0xff, 0x7f, // 8388607
+		}, []int16{-32768, -128, -1, 1, 128, 32767}},
+		{"24-bit stereo", AudioFormat{SampleRate: 16000, Channels: 2, BitsPerSample: 24}, []byte{
+			0x00, 0x01, 0x00, 0x00, 0x03, 0x00,
+			0x00, 0xfc, 0xff, 0x00, 0x00, 0x00,
+		}, []int16{2, -2}},
+		{"32-bit", pcm32, []byte{
+			0x00, 0x00, 0x00, 0x80,
+			0x00, 0x00, 0xff, 0xff,
+			0x00, 0x00, 0x01, 0x00,
+			0xff, 0xff, 0xff, 0x7f,
+		}, []int16{-32768, -1, 1, 32767}},
+		// Floats beyond ±1.0 are clipped.
+		{"float", float, float32s(0, 0.5, -0.5, 1, -1, 1.5, -2, 1e10), []int16{0, 16384, -16384, 32767, -32768, 32767, -32768, 32767}},
+		// A trailing partial frame is ignored.
+		{"partial frame", pcm24, []byte{0x00, 0x01, 0x00, 0x00, 0x01}, []int16{1}},
+	}
+	for _, test := range tests {
+		r, err := ConvertAudio(bytes.NewReader(test.audio), test.format)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got := make([]int16, len(data)/2)
+		for i := range got {
+			got[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestConvertAudioUnsupportedFormats(t *testing.T) {
+	for _, format := range []AudioFormat{
+		{SampleRate: 16000, Channels: 1, BitsPerSample: 8},
+		{SampleRate: 16000, Channels: 1, BitsPerSample: 64, Float: true},
+		{SampleRate: 16000, Channels: 1, BitsPerSample: 16, Float: true},
+		{SampleRate: 16000, Channels: 0, BitsPerSample: 16},
+		{SampleRate: 0, Channels: 1, BitsPerSample: 16},
+	} {
+		if _, err := ConvertAudio(bytes.NewReader(nil), format); err == nil {
+			t.Errorf("%s: got no error", format)
+		} else if _, ok := err.(*AudioFormatError); !ok {
+			t.Errorf("%s: got %v, want an AudioFormatError", format, err)
+		}
+	}
+}
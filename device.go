@@ -0,0 +1,646 @@
+package avs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The interval at which a Device pings AVS to keep its connection alive.
+const PingInterval = 5 * time.Minute
+
+// The longest a Device waits before trying to reconnect to AVS.
+const maxReconnectDelay = 5 * time.Minute
+
+// TokenSource supplies the access tokens that a Device uses. Implementations
+// are expected to refresh tokens before they expire.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// TokenRevoker may be implemented by a TokenSource that should discard its
+// tokens (e.g., a stored refresh token) when the user revokes the device's
+// authorization.
+type TokenRevoker interface {
+	RevokeTokens()
+}
+
+// StaticTokenSource is a TokenSource that always returns the same token.
+type StaticTokenSource string
+
+// Token implements the TokenSource interface.
+func (t StaticTokenSource) Token() (string, error) {
+	return string(t), nil
+}
+
+// Device is a complete AVS client that keeps a connection to AVS alive and
+// handles all directives with the capability agents of this package. The
+// agents are registered as context providers with the Device's client so that
+// their context is attached to the events that require it.
+//
+// A Device is typically created with NewDevice and started with Run:
+//
+//	device := avs.NewDevice(tokens, microphone, speaker)
+//	go device.Run(nil)
+//	device.Listen(audio)
+type Device struct {
+	Client *Client
+	Tokens TokenSource
+	// Microphone is opened when AVS expects the user to speak. It's optional;
+	// if it's nil, the device will report that the user didn't speak.
+	Microphone AudioSource
+	// The ASR profile used for Recognize events.
+	Profile RecognizeProfile
+	// The format of the audio passed to Listen and opened from Microphone. If
+	// it's empty, RecognizeFormatL16 is used.
+	Format RecognizeFormat
+	// Configures how the end of speech is detected for the NEAR_FIELD and
+	// FAR_FIELD profiles.
+	EndpointerConfig EndpointerConfig
+	// FirmwareVersion is optional. If it's set, it's reported to AVS in a
+	// SoftwareInfo event once the device has first connected, and whenever AVS
+	// asks for it. It must be a positive 32-bit integer (e.g., "42").
+	FirmwareVersion string
+	// ErrorHandler is optional. It's called with errors that happen in the
+	// background, such as failing to handle a directive.
+	ErrorHandler func(err error)
+
+	Focus             *FocusManager
+	Alerts            *Alerts
+	AudioPlayer       *AudioPlayer
+	DoNotDisturb      *DoNotDisturb
+	Equalizer         *Equalizer
+	Notifications     *Notifications
+	Settings          *Settings
+	Speaker           *Speaker
+	SpeechSynthesizer *SpeechSynthesizer
+	UserInactivity    *UserInactivity
+	// Bluetooth is optional. It's set by EnableBluetooth.
+	Bluetooth *Bluetooth
+	// TemplateRuntime is optional. Devices with a screen should set it to show
+	// visual cards; otherwise, the cards are ignored.
+	TemplateRuntime *TemplateRuntime
+
+	mu      sync.Mutex
+	capture *captureReader
+	queue   []queuedDirective
+	pending chan struct{}
+	// Whether SoftwareInfo has been sent successfully since the device
+	// started.
+	softwareInfoSent bool
+	revoked          bool
+}
+
+type queuedDirective struct {
+	directive *Message
+	response  *Response
+}
+
+// NewDevice returns a Device which uses a new client for the default endpoint,
+// gets access tokens from the provided token source, opens the microphone with the
+// provided audio source and plays all audio through the provided sink. Each
+// capability agent plays through its own channel of a SharedSink, so that
+// one agent stopping its audio doesn't cut off another's.
+//
+// If the sink also implements SpeakerHardware, it will be used to control the
+// volume of the device. If it implements EqualizerHardware, it will be used
+// with DefaultEqualizerConfiguration for the EqualizerController interface,
+// and if it implements VisualIndicator, it will show the notification
+// indicator.
+func NewDevice(tokens TokenSource, input AudioSource, output AudioSink) *Device {
+	d := &Device{
+		Client:     &Client{EndpointURL: DefaultClient.EndpointURL, AudioConverter: ConvertAudio},
+		Tokens:     tokens,
+		Microphone: input,
+		Profile:    RecognizeProfileCloseTalk,
+		Focus:      NewFocusManager(),
+		pending:    make(chan struct{}, 1),
+	}
+	hardware, ok := output.(SpeakerHardware)
+	if !ok {
+		hardware = nopSpeakerHardware{}
+	}
+	shared := NewSharedSink(output)
+	d.Alerts = NewAlerts(d, shared.Channel(), DefaultAlertTone, DefaultAlertToneFormat)
+	d.Alerts.Focus = d.Focus
+	d.AudioPlayer = NewAudioPlayer(d, shared.Channel())
+	d.AudioPlayer.Focus = d.Focus
+	// These can't fail since there's no store to load from.
+	d.DoNotDisturb, _ = NewDoNotDisturb(d, nil)
+	d.UserInactivity, _ = NewUserInactivity(d, SystemClock, nil)
+	d.UserInactivity.ErrorHandler = d.reportError
+	d.Settings, _ = NewSettings(d, nil)
+	d.Notifications = NewNotifications(shared.Channel())
+	d.Notifications.DoNotDisturb = d.DoNotDisturb
+	d.Notifications.Focus = d.Focus
+	d.Notifications.ErrorHandler = d.reportError
+	d.Notifications.Indicator, _ = output.(VisualIndicator)
+	d.Speaker = NewSpeaker(d, hardware, 50, false)
+	d.SpeechSynthesizer = NewSpeechSynthesizer(d, shared.Channel())
+	d.SpeechSynthesizer.Focus = d.Focus
+	d.Client.AddContextProvider(d.Alerts)
+	d.Client.AddContextProvider(d.AudioPlayer)
+	d.Client.AddContextProvider(d.Notifications)
+	if equalizer, ok := output.(EqualizerHardware); ok {
+		d.Equalizer = NewEqualizer(d, equalizer, DefaultEqualizerConfiguration)
+		d.Client.AddContextProvider(d.Equalizer)
+	}
+	d.Client.AddContextProvider(d.Speaker)
+	d.Client.AddContextProvider(d.SpeechSynthesizer)
+	return d
+}
+
+// EnableBluetooth makes the device handle the Bluetooth directives with the
+// provided adapter and report the BluetoothState context. It should be
+// called before Run.
+func (d *Device) EnableBluetooth(adapter BluetoothAdapter) *Bluetooth {
+	d.Bluetooth = NewBluetooth(d, adapter)
+	d.Client.AddContextProvider(d.Bluetooth)
+	return d.Bluetooth
+}
+
+// Run connects to AVS and handles directives until done is closed. If the
+// connection is lost, Run will reconnect with increasing delays.
+//
+// If the user revokes the device's authorization, Run reports
+// ErrAuthorizationRevoked to the ErrorHandler and returns.
+func (d *Device) Run(done <-chan struct{}) {
+	go d.process(done)
+	go d.UserInactivity.Run(done)
+	delay := time.Second
+	for {
+		connected, err := d.connect(done)
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if err == ErrAuthorizationRevoked || d.authorizationRevoked() {
+			d.reportError(ErrAuthorizationRevoked)
+			return
+		}
+		if err != nil {
+			d.reportError(err)
+		}
+		if connected {
+			delay = time.Second
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// SendEvent sends an event to AVS. Any directives in
+// the response will be handled in the background.
+//
+// SendEvent implements the EventSender interface.
+func (d *Device) SendEvent(event TypedMessage) (*Response, error) {
+	request := NewRequest("")
+	request.Event = event
+	return d.do(request)
+}
+
+// SendRequest sends the request to AVS with an access token from the token
+// source. Any directives in the response will be handled in the background.
+//
+// SendRequest implements the RequestSender interface.
+func (d *Device) SendRequest(request *Request) (*Response, error) {
+	r := *request
+	return d.do(&r)
+}
+
+// Listen sends the audio to AVS as a Recognize event and blocks until AVS has
+// responded. Any speech that is playing is interrupted. The directives in the
+// response will be handled in the background.
+//
+// With the NEAR_FIELD and FAR_FIELD profiles, PCM audio is ended by an
+// Endpointer when the user stops speaking.
+func (d *Device) Listen(audio io.Reader) error {
+	return d.ListenWithInitiator(audio, nil)
+}
+
+// ListenWithInitiator is like Listen, but also tells AVS how the interaction
+// was started (see CaptureBuffer for wake word initiators).
+func (d *Device) ListenWithInitiator(audio io.Reader, initiator *RecognizeInitiator) error {
+	return d.listen(audio, initiator.raw())
+}
+
+// Sends the audio to AVS with a Recognize event that carries the raw
+// initiator.
+func (d *Device) listen(audio io.Reader, initiator json.RawMessage) error {
+	if (d.Profile == RecognizeProfileNearField || d.Profile == RecognizeProfileFarField) && d.Format != RecognizeFormatOpus {
+		pcm, err := RecognizeAudio(audio, d.Client.AudioConverter)
+		if err != nil {
+			return err
+		}
+		audio = NewEndpointer(pcm, d.EndpointerConfig)
+	}
+	dialogRequestId := RandomUUIDString()
+	if err := d.SpeechSynthesizer.Interrupt(dialogRequestId); err != nil {
+		return err
+	}
+	capture := &captureReader{stoppableReader{r: audio}}
+	d.mu.Lock()
+	d.capture = capture
+	d.mu.Unlock()
+	d.Focus.Acquire(FocusChannelDialog, capture)
+	defer d.Focus.Release(FocusChannelDialog, capture)
+	request := NewRequest("")
+	request.Event = NewRecognizeWithRawInitiator(RandomUUIDString(), dialogRequestId, d.Profile, d.Format, initiator)
+	request.Audio = capture
+	request.Format = d.Format
+	_, err := d.do(request)
+	return err
+}
+
+// Stop stops whatever the device is doing in the foreground: listening,
+// speaking or sounding an alert.
+func (d *Device) Stop() error {
+	d.stopCapture()
+	if err := d.SpeechSynthesizer.Interrupt(RandomUUIDString()); err != nil {
+		return err
+	}
+	return d.Alerts.StopActive()
+}
+
+// Pause asks AVS to pause the audio that the device is playing.
+func (d *Device) Pause() error {
+	return d.AudioPlayer.PressButton(PlaybackButtonPause)
+}
+
+// Play asks AVS to resume or start playing audio.
+func (d *Device) Play() error {
+	return d.AudioPlayer.PressButton(PlaybackButtonPlay)
+}
+
+// Next asks AVS to skip to the next audio item.
+func (d *Device) Next() error {
+	return d.AudioPlayer.PressButton(PlaybackButtonNext)
+}
+
+// Previous asks AVS to go back to the previous audio item.
+func (d *Device) Previous() error {
+	return d.AudioPlayer.PressButton(PlaybackButtonPrevious)
+}
+
+// PressButton asks AVS to apply a playback button, such as skipping forward.
+func (d *Device) PressButton(button PlaybackButton) error {
+	return d.AudioPlayer.PressButton(button)
+}
+
+// Toggle asks AVS to turn a playback toggle, such as shuffle, on or off.
+func (d *Device) Toggle(toggle PlaybackToggle, selected bool) error {
+	return d.AudioPlayer.Toggle(toggle, selected)
+}
+
+// Opens a downchannel, synchronizes the state of the device and then handles
+// directives until the downchannel closes or done is closed.
+func (d *Device) connect(done <-chan struct{}) (connected bool, err error) {
+	if d.authorizationRevoked() {
+		return false, ErrAuthorizationRevoked
+	}
+	token, err := d.Tokens.Token()
+	if err != nil {
+		return false, err
+	}
+	// The downchannel is closed whenever this returns, so that reconnecting
+	// doesn't leave it open.
+	stop := make(chan struct{})
+	defer close(stop)
+	directives, err := d.Client.OpenDownchannel(token, stop)
+	if err != nil {
+		return false, err
+	}
+	if _, err := d.SendEvent(NewSynchronizeState(RandomUUIDString())); err != nil {
+		return false, err
+	}
+	if err := d.DoNotDisturb.Report(); err != nil {
+		return false, err
+	}
+	if err := d.Settings.Report(); err != nil {
+		return false, err
+	}
+	d.mu.Lock()
+	sendSoftwareInfo := d.FirmwareVersion != "" && !d.softwareInfoSent
+	d.mu.Unlock()
+	if sendSoftwareInfo {
+		if err := d.sendSoftwareInfo(); err != nil {
+			// Failing to report the version shouldn't take the device offline,
+			// and it will be sent again on the next connect.
+			d.reportError(err)
+		}
+	}
+	ping := time.NewTicker(PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-done:
+			return true, nil
+		case directive, ok := <-directives:
+			if !ok {
+				return true, fmt.Errorf("downchannel closed")
+			}
+			d.enqueue(directive, nil)
+		case <-ping.C:
+			token, err := d.Tokens.Token()
+			if err == nil {
+				err = d.Client.Ping(token)
+			}
+			if err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// Sends a request with a fresh access token and queues the directives of the
+// response.
+func (d *Device) do(request *Request) (*Response, error) {
+	if d.authorizationRevoked() {
+		return nil, ErrAuthorizationRevoked
+	}
+	token, err := d.Tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+	request.AccessToken = token
+	response, err := d.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	// Only requests that reached AVS count as user activity.
+	if err := d.UserInactivity.RecordEvent(request.Event); err != nil {
+		d.reportError(err)
+	}
+	for _, directive := range response.Directives {
+		d.enqueue(directive, response)
+	}
+	return response, nil
+}
+
+// Queues a directive to be handled in order with all other directives.
+func (d *Device) enqueue(directive *Message, response *Response) {
+	d.mu.Lock()
+	d.queue = append(d.queue, queuedDirective{directive, response})
+	d.mu.Unlock()
+	select {
+	case d.pending <- struct{}{}:
+	default:
+	}
+}
+
+// Handles the queued directives until done is closed.
+func (d *Device) process(done <-chan struct{}) {
+	for {
+		d.mu.Lock()
+		if len(d.queue) == 0 {
+			d.mu.Unlock()
+			select {
+			case <-done:
+				return
+			case <-d.pending:
+			}
+			continue
+		}
+		q := d.queue[0]
+		d.queue = d.queue[1:]
+		d.mu.Unlock()
+		if err := d.handle(q.directive, q.response); err != nil {
+			d.reportError(err)
+		}
+	}
+}
+
+// Handles a single directive with the appropriate capability agent.
+func (d *Device) handle(directive *Message, response *Response) error {
+	switch m := directive.Typed().(type) {
+	case *DeleteAlert:
+		return d.Alerts.DeleteAlert(m)
+	case *SetAlert:
+		return d.Alerts.SetAlert(m)
+	case *ClearQueue:
+		return d.AudioPlayer.ClearQueue(m)
+	case *Play:
+		if d.TemplateRuntime != nil {
+			if err := d.TemplateRuntime.Play(m); err != nil {
+				d.reportError(err)
+			}
+		}
+		return d.AudioPlayer.Play(m, response)
+	case *Stop:
+		return d.AudioPlayer.Stop(m)
+	case *ConnectByDeviceIds, *ConnectByProfile, *DisconnectDevices, *EnterDiscoverableMode,
+		*ExitDiscoverableMode, *BluetoothMediaControl, *PairDevices, *ScanDevices, *UnpairDevices:
+		return d.handleBluetooth(directive, m)
+	case *SetDoNotDisturb:
+		return d.DoNotDisturb.SetDoNotDisturb(m)
+	case *AdjustBands:
+		if d.Equalizer == nil {
+			return d.unsupported(directive)
+		}
+		return d.Equalizer.AdjustBands(m)
+	case *ResetBands:
+		if d.Equalizer == nil {
+			return d.unsupported(directive)
+		}
+		return d.Equalizer.ResetBands(m)
+	case *SetBands:
+		if d.Equalizer == nil {
+			return d.unsupported(directive)
+		}
+		return d.Equalizer.SetBands(m)
+	case *SetMode:
+		if d.Equalizer == nil {
+			return d.unsupported(directive)
+		}
+		return d.Equalizer.SetMode(m)
+	case *ClearIndicator:
+		return d.Notifications.ClearIndicator(m)
+	case *SetIndicator:
+		return d.Notifications.SetIndicator(m)
+	case *AdjustVolume:
+		return d.Speaker.AdjustVolume(m)
+	case *SetMute:
+		return d.Speaker.SetMute(m)
+	case *SetVolume:
+		return d.Speaker.SetVolume(m)
+	case *ExpectSpeech:
+		if d.SpeechSynthesizer.ExpectSpeech(m) {
+			go d.expectSpeech(m)
+		}
+		return nil
+	case *StopCapture:
+		d.stopCapture()
+		return nil
+	case *Speak:
+		return d.SpeechSynthesizer.Speak(m, response)
+	case *Exception:
+		return m
+	case *SetEndpoint:
+		// The new endpoint will be used for all requests from now on.
+		d.Client.SetEndpointURL(m.Payload.Endpoint)
+		return nil
+	case *SetLocales:
+		return d.Settings.SetLocales(m)
+	case *SetTimeZone:
+		return d.Settings.SetTimeZone(m)
+	case *ResetUserInactivity:
+		return d.UserInactivity.ResetUserInactivity(m)
+	case *RevokeAuthorization:
+		d.revokeAuthorization()
+		return nil
+	case *ReportSoftwareInfo:
+		if d.FirmwareVersion == "" {
+			return d.unsupported(directive)
+		}
+		return d.sendSoftwareInfo()
+	case *RenderPlayerInfo:
+		if d.TemplateRuntime == nil {
+			return nil
+		}
+		return d.TemplateRuntime.RenderPlayerInfo(m)
+	case *RenderTemplate:
+		if d.TemplateRuntime == nil {
+			return nil
+		}
+		return d.TemplateRuntime.RenderTemplate(m)
+	default:
+		return d.unsupported(directive)
+	}
+}
+
+// Handles a Bluetooth directive, if Bluetooth is enabled.
+func (d *Device) handleBluetooth(directive *Message, m TypedMessage) error {
+	if d.Bluetooth == nil {
+		return d.unsupported(directive)
+	}
+	switch m := m.(type) {
+	case *ConnectByDeviceIds:
+		return d.Bluetooth.ConnectByDeviceIds(m)
+	case *ConnectByProfile:
+		return d.Bluetooth.ConnectByProfile(m)
+	case *DisconnectDevices:
+		return d.Bluetooth.DisconnectDevices(m)
+	case *EnterDiscoverableMode:
+		return d.Bluetooth.EnterDiscoverableMode(m)
+	case *ExitDiscoverableMode:
+		return d.Bluetooth.ExitDiscoverableMode(m)
+	case *BluetoothMediaControl:
+		return d.Bluetooth.MediaControl(m)
+	case *PairDevices:
+		return d.Bluetooth.PairDevices(m)
+	case *ScanDevices:
+		return d.Bluetooth.ScanDevices(m)
+	case *UnpairDevices:
+		return d.Bluetooth.UnpairDevices(m)
+	}
+	return d.unsupported(directive)
+}
+
+// Stops the device from making any more requests, since its access tokens are
+// no longer valid. The client has already closed the downchannel.
+func (d *Device) revokeAuthorization() {
+	d.mu.Lock()
+	d.revoked = true
+	d.mu.Unlock()
+	d.stopCapture()
+	if revoker, ok := d.Tokens.(TokenRevoker); ok {
+		revoker.RevokeTokens()
+	}
+}
+
+func (d *Device) authorizationRevoked() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.revoked
+}
+
+// Sends the SoftwareInfo event with the firmware version of the device.
+func (d *Device) sendSoftwareInfo() error {
+	if v, err := strconv.ParseInt(d.FirmwareVersion, 10, 32); err != nil || v <= 0 {
+		return fmt.Errorf("invalid firmware version %q", d.FirmwareVersion)
+	}
+	if _, err := d.SendEvent(NewSoftwareInfo(RandomUUIDString(), d.FirmwareVersion)); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.softwareInfoSent = true
+	d.mu.Unlock()
+	return nil
+}
+
+// Tells AVS that the device doesn't support the directive.
+func (d *Device) unsupported(directive *Message) error {
+	data, _ := json.Marshal(directive)
+	_, err := d.SendEvent(NewExceptionEncountered(RandomUUIDString(), string(data), ErrorTypeUnsupportedOperation, "unsupported directive "+directive.String()))
+	return err
+}
+
+// Opens the microphone for an ExpectSpeech directive.
+func (d *Device) expectSpeech(m *ExpectSpeech) {
+	if d.Microphone == nil {
+		if _, err := d.SendEvent(NewExpectSpeechTimedOut(RandomUUIDString())); err != nil {
+			d.reportError(err)
+		}
+		return
+	}
+	audio, err := d.Microphone()
+	if err != nil {
+		d.reportError(err)
+		return
+	}
+	defer audio.Close()
+	if err := d.listen(audio, m.Payload.Initiator); err != nil {
+		d.reportError(err)
+	}
+}
+
+func (d *Device) stopCapture() {
+	d.mu.Lock()
+	capture := d.capture
+	d.capture = nil
+	d.mu.Unlock()
+	if capture != nil {
+		capture.stop()
+	}
+}
+
+func (d *Device) reportError(err error) {
+	if d.ErrorHandler != nil {
+		d.ErrorHandler(err)
+	}
+}
+
+// A reader of captured audio which ends when capturing is stopped.
+type captureReader struct {
+	stoppableReader
+}
+
+// FocusChanged implements the FocusObserver interface. Capturing stops if the
+// dialog channel is taken by something else.
+func (c *captureReader) FocusChanged(state FocusState) error {
+	if state == FocusStateNone {
+		c.stop()
+	}
+	return nil
+}
+
+// SpeakerHardware for devices that don't control their volume.
+type nopSpeakerHardware struct{}
+
+func (nopSpeakerHardware) SetVolume(volume int) error {
+	return nil
+}
+
+func (nopSpeakerHardware) SetMute(muted bool) error {
+	return nil
+}
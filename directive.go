@@ -1,6 +1,8 @@
 package avs
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
 )
@@ -46,6 +48,151 @@ type Stop struct {
 	Payload struct{} `json:"payload"`
 }
 
+/********** Bluetooth **********/
+
+// The ConnectByDeviceIds directive.
+type ConnectByDeviceIds struct {
+	*Message
+	Payload struct {
+		Devices []BluetoothDevice `json:"devices"`
+	} `json:"payload"`
+}
+
+// The ConnectByProfile directive.
+type ConnectByProfile struct {
+	*Message
+	Payload struct {
+		Profile BluetoothProfile `json:"profile"`
+	} `json:"payload"`
+}
+
+// The DisconnectDevices directive.
+type DisconnectDevices struct {
+	*Message
+	Payload struct {
+		Devices []BluetoothDevice `json:"devices"`
+	} `json:"payload"`
+}
+
+// The EnterDiscoverableMode directive.
+type EnterDiscoverableMode struct {
+	*Message
+	Payload struct {
+		DurationInSeconds int `json:"durationInSeconds"`
+	} `json:"payload"`
+}
+
+func (m *EnterDiscoverableMode) Duration() time.Duration {
+	return time.Duration(m.Payload.DurationInSeconds) * time.Second
+}
+
+// The ExitDiscoverableMode directive.
+type ExitDiscoverableMode struct {
+	*Message
+	Payload struct{} `json:"payload"`
+}
+
+// The PairDevices directive.
+type PairDevices struct {
+	*Message
+	Payload struct {
+		Devices []BluetoothDevice `json:"devices"`
+	} `json:"payload"`
+}
+
+// The ScanDevices directive.
+type ScanDevices struct {
+	*Message
+	Payload struct{} `json:"payload"`
+}
+
+// The UnpairDevices directive.
+type UnpairDevices struct {
+	*Message
+	Payload struct {
+		Devices []BluetoothDevice `json:"devices"`
+	} `json:"payload"`
+}
+
+// The Bluetooth.Play, Bluetooth.Stop, Bluetooth.Next and Bluetooth.Previous
+// directives, which control the media of the active device.
+type BluetoothMediaControl struct {
+	*Message
+	Payload struct {
+		// The device to control. If it's empty, the active device is controlled.
+		Device BluetoothDevice `json:"device"`
+	} `json:"payload"`
+}
+
+// Command returns the media command of the directive.
+func (m *BluetoothMediaControl) Command() BluetoothMediaCommand {
+	return BluetoothMediaCommand(strings.ToUpper(m.Header["name"]))
+}
+
+/********** DoNotDisturb **********/
+
+// The SetDoNotDisturb directive.
+type SetDoNotDisturb struct {
+	*Message
+	Payload struct {
+		Enabled bool `json:"enabled"`
+	} `json:"payload"`
+}
+
+/********** EqualizerController **********/
+
+// The AdjustBands directive.
+type AdjustBands struct {
+	*Message
+	Payload struct {
+		Bands []EqualizerBandAdjustment `json:"bands"`
+	} `json:"payload"`
+}
+
+// The ResetBands directive.
+type ResetBands struct {
+	*Message
+	Payload struct {
+		Bands []struct {
+			Name EqualizerBand `json:"name"`
+		} `json:"bands"`
+	} `json:"payload"`
+}
+
+// The SetBands directive.
+type SetBands struct {
+	*Message
+	Payload struct {
+		Bands []EqualizerBandLevel `json:"bands"`
+	} `json:"payload"`
+}
+
+// The SetMode directive.
+type SetMode struct {
+	*Message
+	Payload struct {
+		Mode EqualizerMode `json:"mode"`
+	} `json:"payload"`
+}
+
+/********** Notifications **********/
+
+// The ClearIndicator directive.
+type ClearIndicator struct {
+	*Message
+	Payload struct{} `json:"payload"`
+}
+
+// The SetIndicator directive.
+type SetIndicator struct {
+	*Message
+	Payload struct {
+		PersistVisualIndicator bool  `json:"persistVisualIndicator"`
+		PlayAudioIndicator     bool  `json:"playAudioIndicator"`
+		Asset                  Asset `json:"asset"`
+	} `json:"payload"`
+}
+
 /********** Speaker **********/
 
 // The AdjustVolume directive.
@@ -79,6 +226,10 @@ type ExpectSpeech struct {
 	*Message
 	Payload struct {
 		TimeoutInMilliseconds int `json:"timeoutInMilliseconds"`
+		// The initiator which must be sent with the Recognize event that
+		// answers this directive, if any. It's kept as it was received since
+		// it must be sent back unchanged.
+		Initiator json.RawMessage `json:"initiator,omitempty"`
 	} `json:"payload"`
 }
 
@@ -113,6 +264,18 @@ func (m *Speak) ContentId() string {
 
 /********** System **********/
 
+// The ReportSoftwareInfo directive.
+type ReportSoftwareInfo struct {
+	*Message
+	Payload struct{} `json:"payload"`
+}
+
+// The RevokeAuthorization directive.
+type RevokeAuthorization struct {
+	*Message
+	Payload struct{} `json:"payload"`
+}
+
 // The SetEndpoint directive.
 type SetEndpoint struct {
 	*Message
@@ -121,8 +284,71 @@ type SetEndpoint struct {
 	} `json:"payload"`
 }
 
+// The SetLocales directive.
+type SetLocales struct {
+	*Message
+	Payload struct {
+		Locales []SettingLocale `json:"locales"`
+	} `json:"payload"`
+}
+
+// The SetTimeZone directive.
+type SetTimeZone struct {
+	*Message
+	Payload struct {
+		TimeZone string `json:"timeZone"`
+	} `json:"payload"`
+}
+
 // The ResetUserInactivity directive.
 type ResetUserInactivity struct {
 	*Message
 	Payload struct{} `json:"payload"`
 }
+
+/********** TemplateRuntime **********/
+
+// The RenderPlayerInfo directive.
+type RenderPlayerInfo struct {
+	*Message
+	Payload struct {
+		AudioItemId string          `json:"audioItemId"`
+		Content     PlayerContent   `json:"content"`
+		Controls    []PlayerControl `json:"controls"`
+	} `json:"payload"`
+}
+
+// The RenderTemplate directive. Only the fields that all templates have are
+// in the payload; use Template to get the rest.
+type RenderTemplate struct {
+	*Message
+	Payload struct {
+		Token     string        `json:"token"`
+		Type      TemplateType  `json:"type"`
+		Title     TemplateTitle `json:"title"`
+		SkillIcon *Image        `json:"skillIcon,omitempty"`
+	} `json:"payload"`
+}
+
+// Template returns the full payload of the directive, which is one of
+// *BodyTemplate1, *BodyTemplate2, *ListTemplate1 or *WeatherTemplate
+// depending on its type.
+func (m *RenderTemplate) Template() (interface{}, error) {
+	var template interface{}
+	switch m.Payload.Type {
+	case TemplateTypeBodyTemplate1:
+		template = new(BodyTemplate1)
+	case TemplateTypeBodyTemplate2:
+		template = new(BodyTemplate2)
+	case TemplateTypeListTemplate1:
+		template = new(ListTemplate1)
+	case TemplateTypeWeatherTemplate:
+		template = new(WeatherTemplate)
+	default:
+		return nil, fmt.Errorf("unsupported template type %s", m.Payload.Type)
+	}
+	if err := json.Unmarshal(m.Message.Payload, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
@@ -0,0 +1,115 @@
+package avs
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DoNotDisturbStore persists the do not disturb setting so that it survives
+// restarts.
+type DoNotDisturbStore interface {
+	// LoadDoNotDisturb returns the persisted setting, or false if nothing has
+	// been persisted yet.
+	LoadDoNotDisturb() (bool, error)
+	SaveDoNotDisturb(enabled bool) error
+}
+
+// FileDoNotDisturbStore is a DoNotDisturbStore that keeps the setting in a
+// file.
+type FileDoNotDisturbStore string
+
+// LoadDoNotDisturb implements the DoNotDisturbStore interface.
+func (path FileDoNotDisturbStore) LoadDoNotDisturb() (bool, error) {
+	data, err := ioutil.ReadFile(string(path))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.TrimSpace(string(data)))
+}
+
+// SaveDoNotDisturb implements the DoNotDisturbStore interface.
+func (path FileDoNotDisturbStore) SaveDoNotDisturb(enabled bool) error {
+	return ioutil.WriteFile(string(path), []byte(strconv.FormatBool(enabled)), 0666)
+}
+
+// DoNotDisturb keeps the do not disturb setting of the device, which AVS may
+// change with the SetDoNotDisturb directive and the user may change on the
+// device. While it's enabled, Notifications don't play audio indicators.
+// Alarms, timers and reminders still sound.
+type DoNotDisturb struct {
+	Sender EventSender
+	// Store is optional. If it's nil, the setting is only kept in memory.
+	Store DoNotDisturbStore
+
+	mu      sync.Mutex
+	enabled bool
+}
+
+// NewDoNotDisturb returns a DoNotDisturb which restores the setting from the
+// provided store (which may be nil).
+func NewDoNotDisturb(sender EventSender, store DoNotDisturbStore) (*DoNotDisturb, error) {
+	dnd := &DoNotDisturb{
+		Sender: sender,
+		Store:  store,
+	}
+	if store != nil {
+		enabled, err := store.LoadDoNotDisturb()
+		if err != nil {
+			return nil, err
+		}
+		dnd.enabled = enabled
+	}
+	return dnd, nil
+}
+
+// Enabled returns whether do not disturb is enabled.
+func (dnd *DoNotDisturb) Enabled() bool {
+	dnd.mu.Lock()
+	defer dnd.mu.Unlock()
+	return dnd.enabled
+}
+
+// SetDoNotDisturb applies the SetDoNotDisturb directive and confirms the new
+// setting to AVS with the DoNotDisturbChanged event.
+func (dnd *DoNotDisturb) SetDoNotDisturb(d *SetDoNotDisturb) error {
+	if err := dnd.set(d.Payload.Enabled); err != nil {
+		return err
+	}
+	_, err := dnd.Sender.SendEvent(NewDoNotDisturbChanged(RandomUUIDString(), d.Payload.Enabled))
+	return err
+}
+
+// SetEnabled changes the setting because the user changed it on the device,
+// and reports it with the ReportDoNotDisturb event.
+func (dnd *DoNotDisturb) SetEnabled(enabled bool) error {
+	if err := dnd.set(enabled); err != nil {
+		return err
+	}
+	return dnd.Report()
+}
+
+// Report sends a ReportDoNotDisturb event with the current setting, e.g.,
+// after connecting to AVS.
+func (dnd *DoNotDisturb) Report() error {
+	_, err := dnd.Sender.SendEvent(NewReportDoNotDisturb(RandomUUIDString(), dnd.Enabled()))
+	return err
+}
+
+// Changes and persists the setting.
+func (dnd *DoNotDisturb) set(enabled bool) error {
+	dnd.mu.Lock()
+	defer dnd.mu.Unlock()
+	if dnd.Store != nil {
+		if err := dnd.Store.SaveDoNotDisturb(enabled); err != nil {
+			return err
+		}
+	}
+	dnd.enabled = enabled
+	return nil
+}
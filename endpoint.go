@@ -0,0 +1,228 @@
+package avs
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// EndpointReason describes why an Endpointer ended its audio stream.
+type EndpointReason string
+
+// Possible values for EndpointReason.
+const (
+	// EndpointReasonNone means that the stream hasn't ended yet.
+	EndpointReasonNone = EndpointReason("")
+	// EndpointReasonSilence means that the user stopped speaking.
+	EndpointReasonSilence = EndpointReason("SILENCE")
+	// EndpointReasonMaxLength means that the utterance reached its maximum
+	// length.
+	EndpointReasonMaxLength = EndpointReason("MAX_LENGTH")
+	// EndpointReasonNoSpeech means that the user didn't start speaking in time.
+	EndpointReasonNoSpeech = EndpointReason("NO_SPEECH")
+	// EndpointReasonEOF means that the underlying audio stream ended.
+	EndpointReasonEOF = EndpointReason("EOF")
+)
+
+// The duration of the frames that an Endpointer analyzes.
+const endpointFrameDuration = 20 * time.Millisecond
+
+// The highest initial estimate of the noise floor, in dBFS.
+const maxInitialNoiseFloor = -45
+
+// EndpointerConfig configures the voice activity detection of an Endpointer.
+// Zero values are replaced with the values of DefaultEndpointerConfig.
+type EndpointerConfig struct {
+	// How much louder than the estimated noise floor (in dB) a frame must be to
+	// count as speech.
+	Threshold float64
+	// Frames with more zero crossings per sample than this count as (unvoiced)
+	// speech if they're at least half as far above the noise floor as
+	// Threshold requires.
+	ZeroCrossingRate float64
+	// How long speech must last before the user is considered to be speaking.
+	MinSpeech time.Duration
+	// How long the user is still considered to be speaking after the last
+	// frame of speech, so that short pauses don't count as silence.
+	Hangover time.Duration
+	// How long the user must be silent after speaking for the stream to end.
+	TrailingSilence time.Duration
+	// The maximum length of the stream.
+	MaxLength time.Duration
+	// How long to wait for the user to start speaking. If it's negative, the
+	// Endpointer waits until MaxLength.
+	NoSpeechTimeout time.Duration
+}
+
+// DefaultEndpointerConfig is the default configuration of an Endpointer.
+var DefaultEndpointerConfig = EndpointerConfig{
+	Threshold:        12,
+	ZeroCrossingRate: 0.3,
+	MinSpeech:        60 * time.Millisecond,
+	Hangover:         200 * time.Millisecond,
+	TrailingSilence:  600 * time.Millisecond,
+	MaxLength:        10 * time.Second,
+	NoSpeechTimeout:  8 * time.Second,
+}
+
+// Endpointer wraps a stream of audio in RecognizeAudioFormat and ends it once
+// the user has stopped speaking, which AVS expects for the NEAR_FIELD and
+// FAR_FIELD profiles. All audio up to that point is passed through unchanged.
+type Endpointer struct {
+	r      io.Reader
+	config EndpointerConfig
+	reason EndpointReason
+
+	// Audio which has been analyzed but not yet returned by Read.
+	out []byte
+	// Audio which doesn't make up a complete frame yet.
+	frame []byte
+
+	frames int
+	// The estimated energy of the background noise, in dBFS.
+	noiseFloor float64
+	// The number of consecutive speech frames, and the number of frames since
+	// the last one.
+	speechRun, sinceSpeech int
+	speaking               bool
+}
+
+// NewEndpointer returns an Endpointer which reads audio from r. Zero values
+// in the configuration are replaced with defaults.
+func NewEndpointer(r io.Reader, config EndpointerConfig) *Endpointer {
+	d := DefaultEndpointerConfig
+	if config.Threshold == 0 {
+		config.Threshold = d.Threshold
+	}
+	if config.ZeroCrossingRate == 0 {
+		config.ZeroCrossingRate = d.ZeroCrossingRate
+	}
+	if config.MinSpeech == 0 {
+		config.MinSpeech = d.MinSpeech
+	}
+	if config.Hangover == 0 {
+		config.Hangover = d.Hangover
+	}
+	if config.TrailingSilence == 0 {
+		config.TrailingSilence = d.TrailingSilence
+	}
+	if config.MaxLength == 0 {
+		config.MaxLength = d.MaxLength
+	}
+	if config.NoSpeechTimeout == 0 {
+		config.NoSpeechTimeout = d.NoSpeechTimeout
+	}
+	return &Endpointer{r: r, config: config}
+}
+
+// Reason returns why the stream ended, or EndpointReasonNone if it hasn't.
+func (e *Endpointer) Reason() EndpointReason {
+	return e.reason
+}
+
+// Speaking returns whether the user is currently considered to be speaking.
+func (e *Endpointer) Speaking() bool {
+	return e.speaking
+}
+
+func (e *Endpointer) Read(p []byte) (int, error) {
+	if len(e.out) == 0 && e.reason != EndpointReasonNone {
+		return 0, io.EOF
+	}
+	if len(e.out) == 0 {
+		frameSize := e.frameSamples() * 2
+		buf := make([]byte, len(p))
+		n, err := e.r.Read(buf)
+		for _, b := range buf[:n] {
+			e.frame = append(e.frame, b)
+			if len(e.frame) < frameSize {
+				continue
+			}
+			e.out = append(e.out, e.frame...)
+			e.analyze(e.frame)
+			e.frame = e.frame[:0]
+			if e.reason != EndpointReasonNone {
+				break
+			}
+		}
+		if err == io.EOF && e.reason == EndpointReasonNone {
+			e.out = append(e.out, e.frame...)
+			e.reason = EndpointReasonEOF
+		} else if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if len(e.out) == 0 {
+			if e.reason != EndpointReasonNone {
+				return 0, io.EOF
+			}
+			return 0, nil
+		}
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// Analyzes a frame of audio and updates the state of the Endpointer.
+func (e *Endpointer) analyze(frame []byte) {
+	var sum float64
+	crossings := 0
+	var previous int16
+	samples := len(frame) / 2
+	for i := 0; i < samples; i++ {
+		s := int16(binary.LittleEndian.Uint16(frame[2*i:]))
+		v := float64(s) / 32768
+		sum += v * v
+		if i > 0 && (s >= 0) != (previous >= 0) {
+			crossings++
+		}
+		previous = s
+	}
+	energy := 10 * math.Log10(sum/float64(samples)+1e-10)
+	zcr := float64(crossings) / float64(samples)
+	if e.frames == 0 {
+		// Don't let speech at the very start of the stream be mistaken for noise.
+		e.noiseFloor = math.Min(energy, maxInitialNoiseFloor)
+	}
+	e.frames++
+	isSpeech := energy > e.noiseFloor+e.config.Threshold ||
+		(zcr > e.config.ZeroCrossingRate && energy > e.noiseFloor+e.config.Threshold/2)
+	if isSpeech {
+		e.speechRun++
+		if e.speechRun >= e.framesIn(e.config.MinSpeech) {
+			e.speaking = true
+		}
+		if e.speaking {
+			e.sinceSpeech = 0
+		}
+	} else {
+		e.speechRun = 0
+		e.sinceSpeech++
+		// Track the noise floor, adapting quickly when it gets quieter.
+		if energy < e.noiseFloor {
+			e.noiseFloor = energy
+		} else {
+			e.noiseFloor = 0.95*e.noiseFloor + 0.05*energy
+		}
+	}
+	switch {
+	case e.frames >= e.framesIn(e.config.MaxLength):
+		e.reason = EndpointReasonMaxLength
+	case e.speaking && e.sinceSpeech >= e.framesIn(e.config.Hangover+e.config.TrailingSilence):
+		e.speaking = false
+		e.reason = EndpointReasonSilence
+	case !e.speaking && e.config.NoSpeechTimeout > 0 && e.frames >= e.framesIn(e.config.NoSpeechTimeout):
+		e.reason = EndpointReasonNoSpeech
+	}
+}
+
+// The number of samples in each analyzed frame.
+func (e *Endpointer) frameSamples() int {
+	return int(endpointFrameDuration.Seconds() * float64(RecognizeAudioFormat.SampleRate))
+}
+
+// The number of frames in the duration, rounded up.
+func (e *Endpointer) framesIn(d time.Duration) int {
+	return int((d + endpointFrameDuration - 1) / endpointFrameDuration)
+}
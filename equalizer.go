@@ -0,0 +1,313 @@
+package avs
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EqualizerBand identifies a frequency band of the equalizer.
+type EqualizerBand string
+
+// Possible values for EqualizerBand.
+const (
+	EqualizerBandBass     = EqualizerBand("BASS")
+	EqualizerBandMidrange = EqualizerBand("MIDRANGE")
+	EqualizerBandTreble   = EqualizerBand("TREBLE")
+)
+
+// EqualizerMode is a predefined equalizer setting for a type of content.
+type EqualizerMode string
+
+// Possible values for EqualizerMode.
+const (
+	EqualizerModeMovie = EqualizerMode("MOVIE")
+	EqualizerModeMusic = EqualizerMode("MUSIC")
+	EqualizerModeNight = EqualizerMode("NIGHT")
+	EqualizerModeSport = EqualizerMode("SPORT")
+	EqualizerModeTV    = EqualizerMode("TV")
+)
+
+// LevelDirection specifies whether the level of a band is adjusted up or
+// down.
+type LevelDirection string
+
+// Possible values for LevelDirection.
+const (
+	LevelDirectionUp   = LevelDirection("UP")
+	LevelDirectionDown = LevelDirection("DOWN")
+)
+
+// EqualizerBandLevel is the level of a single band.
+type EqualizerBandLevel struct {
+	Name  EqualizerBand `json:"name"`
+	Level int           `json:"level"`
+}
+
+// EqualizerBandAdjustment is a relative change to the level of a band. If
+// LevelDelta is zero, the band is adjusted by the default step.
+type EqualizerBandAdjustment struct {
+	Name           EqualizerBand  `json:"name"`
+	LevelDelta     int            `json:"levelDelta,omitempty"`
+	LevelDirection LevelDirection `json:"levelDirection"`
+}
+
+// EqualizerConfiguration describes the bands, level range and modes that the
+// equalizer supports. It's encoded as the configuration of the
+// EqualizerController capability.
+type EqualizerConfiguration struct {
+	Bands struct {
+		Supported []EqualizerBandName `json:"supported"`
+		Range     struct {
+			Minimum int `json:"minimum"`
+			Maximum int `json:"maximum"`
+		} `json:"range"`
+	} `json:"bands"`
+	Modes struct {
+		Supported []EqualizerModeName `json:"supported"`
+	} `json:"modes"`
+}
+
+// EqualizerBandName is a supported band in an EqualizerConfiguration.
+type EqualizerBandName struct {
+	Name EqualizerBand `json:"name"`
+}
+
+// EqualizerModeName is a supported mode in an EqualizerConfiguration.
+type EqualizerModeName struct {
+	Name EqualizerMode `json:"name"`
+}
+
+// NewEqualizerConfiguration returns a configuration for an equalizer with the
+// provided bands and modes, whose levels range from min to max.
+func NewEqualizerConfiguration(bands []EqualizerBand, min, max int, modes []EqualizerMode) EqualizerConfiguration {
+	var c EqualizerConfiguration
+	c.Bands.Supported = []EqualizerBandName{}
+	c.Modes.Supported = []EqualizerModeName{}
+	for _, band := range bands {
+		c.Bands.Supported = append(c.Bands.Supported, EqualizerBandName{band})
+	}
+	c.Bands.Range.Minimum = min
+	c.Bands.Range.Maximum = max
+	for _, mode := range modes {
+		c.Modes.Supported = append(c.Modes.Supported, EqualizerModeName{mode})
+	}
+	return c
+}
+
+// DefaultEqualizerConfiguration has the bass, midrange and treble bands with
+// levels from -6 to 6, and no modes.
+var DefaultEqualizerConfiguration = NewEqualizerConfiguration(
+	[]EqualizerBand{EqualizerBandBass, EqualizerBandMidrange, EqualizerBandTreble}, -6, 6, nil)
+
+// Returns whether the band is supported.
+func (c *EqualizerConfiguration) supportsBand(band EqualizerBand) bool {
+	for _, b := range c.Bands.Supported {
+		if b.Name == band {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether the mode is supported.
+func (c *EqualizerConfiguration) supportsMode(mode EqualizerMode) bool {
+	for _, m := range c.Modes.Supported {
+		if m.Name == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits the level to the supported range.
+func (c *EqualizerConfiguration) clampLevel(level int) int {
+	if level < c.Bands.Range.Minimum {
+		return c.Bands.Range.Minimum
+	}
+	if level > c.Bands.Range.Maximum {
+		return c.Bands.Range.Maximum
+	}
+	return level
+}
+
+// EqualizerHardware applies equalizer settings to the device's audio output.
+type EqualizerHardware interface {
+	// SetEqualizer sets the level of every supported band and the mode, which
+	// is empty if no mode is selected.
+	SetEqualizer(bands []EqualizerBandLevel, mode EqualizerMode) error
+}
+
+// Equalizer applies the EqualizerController directives to the equalizer
+// hardware and reports the EqualizerChanged event to AVS. Local equalizer
+// controls should also go through the Equalizer so that AVS is kept up to
+// date.
+type Equalizer struct {
+	Sender   EventSender
+	Hardware EqualizerHardware
+	Config   EqualizerConfiguration
+	// The amount that a band changes with an adjustment that doesn't specify
+	// it.
+	Step int
+
+	mu     sync.Mutex
+	levels map[EqualizerBand]int
+	mode   EqualizerMode
+}
+
+// NewEqualizer returns an Equalizer for the provided hardware, with all bands
+// at level 0 (or the closest supported level) and no mode.
+func NewEqualizer(sender EventSender, hardware EqualizerHardware, config EqualizerConfiguration) *Equalizer {
+	e := &Equalizer{
+		Sender:   sender,
+		Hardware: hardware,
+		Config:   config,
+		Step:     1,
+		levels:   make(map[EqualizerBand]int),
+	}
+	for _, band := range config.Bands.Supported {
+		e.levels[band.Name] = config.clampLevel(0)
+	}
+	return e
+}
+
+// AdjustBands applies the AdjustBands directive.
+func (e *Equalizer) AdjustBands(d *AdjustBands) error {
+	return e.update(func(levels map[EqualizerBand]int) error {
+		for _, adjustment := range d.Payload.Bands {
+			delta := adjustment.LevelDelta
+			if delta == 0 {
+				delta = e.Step
+			}
+			if adjustment.LevelDirection == LevelDirectionDown {
+				delta = -delta
+			}
+			e.adjust(levels, adjustment.Name, delta)
+		}
+		return nil
+	})
+}
+
+// ResetBands applies the ResetBands directive.
+func (e *Equalizer) ResetBands(d *ResetBands) error {
+	return e.update(func(levels map[EqualizerBand]int) error {
+		for _, band := range d.Payload.Bands {
+			e.set(levels, band.Name, 0)
+		}
+		return nil
+	})
+}
+
+// SetBands applies the SetBands directive.
+func (e *Equalizer) SetBands(d *SetBands) error {
+	return e.update(func(levels map[EqualizerBand]int) error {
+		for _, band := range d.Payload.Bands {
+			e.set(levels, band.Name, band.Level)
+		}
+		return nil
+	})
+}
+
+// SetMode applies the SetMode directive.
+func (e *Equalizer) SetMode(d *SetMode) error {
+	return e.setMode(d.Payload.Mode)
+}
+
+// SetLevel sets the level of a band, as if the user changed it on the device.
+func (e *Equalizer) SetLevel(band EqualizerBand, level int) error {
+	return e.update(func(levels map[EqualizerBand]int) error {
+		if !e.Config.supportsBand(band) {
+			return fmt.Errorf("unsupported equalizer band %s", band)
+		}
+		e.set(levels, band, level)
+		return nil
+	})
+}
+
+// AdjustLevel changes the level of a band by delta, as if the user changed it
+// on the device.
+func (e *Equalizer) AdjustLevel(band EqualizerBand, delta int) error {
+	return e.update(func(levels map[EqualizerBand]int) error {
+		if !e.Config.supportsBand(band) {
+			return fmt.Errorf("unsupported equalizer band %s", band)
+		}
+		e.adjust(levels, band, delta)
+		return nil
+	})
+}
+
+// SelectMode selects a mode, as if the user changed it on the device.
+func (e *Equalizer) SelectMode(mode EqualizerMode) error {
+	return e.setMode(mode)
+}
+
+// EqualizerState returns the current EqualizerState context.
+func (e *Equalizer) EqualizerState() *EqualizerState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return NewEqualizerState(e.bandLevels(e.levels), e.mode)
+}
+
+// Context implements the ContextProvider interface.
+func (e *Equalizer) Context() TypedMessage {
+	return e.EqualizerState()
+}
+
+func (e *Equalizer) setMode(mode EqualizerMode) error {
+	if !e.Config.supportsMode(mode) {
+		return fmt.Errorf("unsupported equalizer mode %s", mode)
+	}
+	e.mu.Lock()
+	if err := e.Hardware.SetEqualizer(e.bandLevels(e.levels), mode); err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	e.mode = mode
+	event := NewEqualizerChanged(RandomUUIDString(), e.bandLevels(e.levels), e.mode)
+	e.mu.Unlock()
+	_, err := e.Sender.SendEvent(event)
+	return err
+}
+
+// Calculates new levels from a copy of the current ones and applies them.
+func (e *Equalizer) update(f func(levels map[EqualizerBand]int) error) error {
+	e.mu.Lock()
+	levels := make(map[EqualizerBand]int, len(e.levels))
+	for band, level := range e.levels {
+		levels[band] = level
+	}
+	if err := f(levels); err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	if err := e.Hardware.SetEqualizer(e.bandLevels(levels), e.mode); err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	e.levels = levels
+	event := NewEqualizerChanged(RandomUUIDString(), e.bandLevels(e.levels), e.mode)
+	e.mu.Unlock()
+	_, err := e.Sender.SendEvent(event)
+	return err
+}
+
+// Sets the level of a band, clamped to the supported range. Unsupported bands
+// are ignored.
+func (e *Equalizer) set(levels map[EqualizerBand]int, band EqualizerBand, level int) {
+	if _, ok := levels[band]; ok {
+		levels[band] = e.Config.clampLevel(level)
+	}
+}
+
+// Changes the level of a band by delta, clamped to the supported range.
+func (e *Equalizer) adjust(levels map[EqualizerBand]int, band EqualizerBand, delta int) {
+	e.set(levels, band, levels[band]+delta)
+}
+
+// Returns the levels in the order of the supported bands.
+func (e *Equalizer) bandLevels(levels map[EqualizerBand]int) []EqualizerBandLevel {
+	bands := []EqualizerBandLevel{}
+	for _, band := range e.Config.Bands.Supported {
+		bands = append(bands, EqualizerBandLevel{band.Name, levels[band.Name]})
+	}
+	return bands
+}
@@ -1,6 +1,8 @@
 package avs
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -373,8 +375,452 @@ func NewStreamMetadataExtracted(messageId, token string, metadata map[string]int
 	return m
 }
 
+/********** Bluetooth **********/
+
+// Also used by the events that report the result of a Bluetooth directive for
+// a list of devices.
+type bluetoothDevices struct {
+	Requester BluetoothRequester `json:"requester,omitempty"`
+	Devices   []BluetoothDevice  `json:"devices"`
+}
+
+// Also used by the events about a single Bluetooth device.
+type bluetoothDevice struct {
+	Device BluetoothDevice `json:"device"`
+}
+
+// The ConnectByDeviceIdsFailed event.
+type ConnectByDeviceIdsFailed struct {
+	*Message
+	Payload bluetoothDevices `json:"payload"`
+}
+
+func NewConnectByDeviceIdsFailed(messageId string, requester BluetoothRequester, devices []BluetoothDevice) *ConnectByDeviceIdsFailed {
+	m := new(ConnectByDeviceIdsFailed)
+	m.Message = newEvent("Bluetooth", "ConnectByDeviceIdsFailed", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.Devices = devices
+	return m
+}
+
+// The ConnectByDeviceIdsSucceeded event.
+type ConnectByDeviceIdsSucceeded struct {
+	*Message
+	Payload bluetoothDevices `json:"payload"`
+}
+
+func NewConnectByDeviceIdsSucceeded(messageId string, requester BluetoothRequester, devices []BluetoothDevice) *ConnectByDeviceIdsSucceeded {
+	m := new(ConnectByDeviceIdsSucceeded)
+	m.Message = newEvent("Bluetooth", "ConnectByDeviceIdsSucceeded", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.Devices = devices
+	return m
+}
+
+// Also used by the events that report the result of a ConnectByProfile
+// directive.
+type bluetoothProfileConnection struct {
+	Requester   BluetoothRequester `json:"requester"`
+	ProfileName string             `json:"profileName"`
+}
+
+// The ConnectByProfileFailed event.
+type ConnectByProfileFailed struct {
+	*Message
+	Payload bluetoothProfileConnection `json:"payload"`
+}
+
+func NewConnectByProfileFailed(messageId string, requester BluetoothRequester, profileName string) *ConnectByProfileFailed {
+	m := new(ConnectByProfileFailed)
+	m.Message = newEvent("Bluetooth", "ConnectByProfileFailed", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.ProfileName = profileName
+	return m
+}
+
+// The ConnectByProfileSucceeded event.
+type ConnectByProfileSucceeded struct {
+	*Message
+	Payload struct {
+		bluetoothProfileConnection
+		Device BluetoothDevice `json:"device"`
+	} `json:"payload"`
+}
+
+func NewConnectByProfileSucceeded(messageId string, requester BluetoothRequester, profileName string, device BluetoothDevice) *ConnectByProfileSucceeded {
+	m := new(ConnectByProfileSucceeded)
+	m.Message = newEvent("Bluetooth", "ConnectByProfileSucceeded", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.ProfileName = profileName
+	m.Payload.Device = device
+	return m
+}
+
+// The DisconnectDevicesFailed event.
+type DisconnectDevicesFailed struct {
+	*Message
+	Payload bluetoothDevices `json:"payload"`
+}
+
+func NewDisconnectDevicesFailed(messageId string, requester BluetoothRequester, devices []BluetoothDevice) *DisconnectDevicesFailed {
+	m := new(DisconnectDevicesFailed)
+	m.Message = newEvent("Bluetooth", "DisconnectDevicesFailed", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.Devices = devices
+	return m
+}
+
+// The DisconnectDevicesSucceeded event.
+type DisconnectDevicesSucceeded struct {
+	*Message
+	Payload bluetoothDevices `json:"payload"`
+}
+
+func NewDisconnectDevicesSucceeded(messageId string, requester BluetoothRequester, devices []BluetoothDevice) *DisconnectDevicesSucceeded {
+	m := new(DisconnectDevicesSucceeded)
+	m.Message = newEvent("Bluetooth", "DisconnectDevicesSucceeded", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.Devices = devices
+	return m
+}
+
+// The EnterDiscoverableModeFailed event.
+type EnterDiscoverableModeFailed struct {
+	*Message
+	Payload struct{} `json:"payload"`
+}
+
+func NewEnterDiscoverableModeFailed(messageId string) *EnterDiscoverableModeFailed {
+	m := new(EnterDiscoverableModeFailed)
+	m.Message = newEvent("Bluetooth", "EnterDiscoverableModeFailed", messageId, "")
+	return m
+}
+
+// The EnterDiscoverableModeSucceeded event.
+type EnterDiscoverableModeSucceeded struct {
+	*Message
+	Payload struct{} `json:"payload"`
+}
+
+func NewEnterDiscoverableModeSucceeded(messageId string) *EnterDiscoverableModeSucceeded {
+	m := new(EnterDiscoverableModeSucceeded)
+	m.Message = newEvent("Bluetooth", "EnterDiscoverableModeSucceeded", messageId, "")
+	return m
+}
+
+// The MediaControlNextFailed event.
+type MediaControlNextFailed struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewMediaControlNextFailed(messageId string, device BluetoothDevice) *MediaControlNextFailed {
+	m := new(MediaControlNextFailed)
+	m.Message = newEvent("Bluetooth", "MediaControlNextFailed", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The MediaControlNextSucceeded event.
+type MediaControlNextSucceeded struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewMediaControlNextSucceeded(messageId string, device BluetoothDevice) *MediaControlNextSucceeded {
+	m := new(MediaControlNextSucceeded)
+	m.Message = newEvent("Bluetooth", "MediaControlNextSucceeded", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The MediaControlPlayFailed event.
+type MediaControlPlayFailed struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewMediaControlPlayFailed(messageId string, device BluetoothDevice) *MediaControlPlayFailed {
+	m := new(MediaControlPlayFailed)
+	m.Message = newEvent("Bluetooth", "MediaControlPlayFailed", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The MediaControlPlaySucceeded event.
+type MediaControlPlaySucceeded struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewMediaControlPlaySucceeded(messageId string, device BluetoothDevice) *MediaControlPlaySucceeded {
+	m := new(MediaControlPlaySucceeded)
+	m.Message = newEvent("Bluetooth", "MediaControlPlaySucceeded", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The MediaControlPreviousFailed event.
+type MediaControlPreviousFailed struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewMediaControlPreviousFailed(messageId string, device BluetoothDevice) *MediaControlPreviousFailed {
+	m := new(MediaControlPreviousFailed)
+	m.Message = newEvent("Bluetooth", "MediaControlPreviousFailed", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The MediaControlPreviousSucceeded event.
+type MediaControlPreviousSucceeded struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewMediaControlPreviousSucceeded(messageId string, device BluetoothDevice) *MediaControlPreviousSucceeded {
+	m := new(MediaControlPreviousSucceeded)
+	m.Message = newEvent("Bluetooth", "MediaControlPreviousSucceeded", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The MediaControlStopFailed event.
+type MediaControlStopFailed struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewMediaControlStopFailed(messageId string, device BluetoothDevice) *MediaControlStopFailed {
+	m := new(MediaControlStopFailed)
+	m.Message = newEvent("Bluetooth", "MediaControlStopFailed", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The MediaControlStopSucceeded event.
+type MediaControlStopSucceeded struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewMediaControlStopSucceeded(messageId string, device BluetoothDevice) *MediaControlStopSucceeded {
+	m := new(MediaControlStopSucceeded)
+	m.Message = newEvent("Bluetooth", "MediaControlStopSucceeded", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The PairDevicesFailed event.
+type PairDevicesFailed struct {
+	*Message
+	Payload bluetoothDevices `json:"payload"`
+}
+
+func NewPairDevicesFailed(messageId string, requester BluetoothRequester, devices []BluetoothDevice) *PairDevicesFailed {
+	m := new(PairDevicesFailed)
+	m.Message = newEvent("Bluetooth", "PairDevicesFailed", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.Devices = devices
+	return m
+}
+
+// The PairDevicesSucceeded event.
+type PairDevicesSucceeded struct {
+	*Message
+	Payload bluetoothDevices `json:"payload"`
+}
+
+func NewPairDevicesSucceeded(messageId string, requester BluetoothRequester, devices []BluetoothDevice) *PairDevicesSucceeded {
+	m := new(PairDevicesSucceeded)
+	m.Message = newEvent("Bluetooth", "PairDevicesSucceeded", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.Devices = devices
+	return m
+}
+
+// The ScanDevicesFailed event.
+type ScanDevicesFailed struct {
+	*Message
+	Payload struct{} `json:"payload"`
+}
+
+func NewScanDevicesFailed(messageId string) *ScanDevicesFailed {
+	m := new(ScanDevicesFailed)
+	m.Message = newEvent("Bluetooth", "ScanDevicesFailed", messageId, "")
+	return m
+}
+
+// The ScanDevicesReport event.
+type ScanDevicesReport struct {
+	*Message
+	Payload struct {
+		DiscoveredDevices []BluetoothDevice `json:"discoveredDevices"`
+		HasMoreDevices    bool              `json:"hasMoreDevices"`
+	} `json:"payload"`
+}
+
+func NewScanDevicesReport(messageId string, devices []BluetoothDevice, hasMore bool) *ScanDevicesReport {
+	m := new(ScanDevicesReport)
+	m.Message = newEvent("Bluetooth", "ScanDevicesReport", messageId, "")
+	m.Payload.DiscoveredDevices = devices
+	m.Payload.HasMoreDevices = hasMore
+	return m
+}
+
+// The StreamingEnded event.
+type StreamingEnded struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewStreamingEnded(messageId string, device BluetoothDevice) *StreamingEnded {
+	m := new(StreamingEnded)
+	m.Message = newEvent("Bluetooth", "StreamingEnded", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The StreamingStarted event.
+type StreamingStarted struct {
+	*Message
+	Payload bluetoothDevice `json:"payload"`
+}
+
+func NewStreamingStarted(messageId string, device BluetoothDevice) *StreamingStarted {
+	m := new(StreamingStarted)
+	m.Message = newEvent("Bluetooth", "StreamingStarted", messageId, "")
+	m.Payload.Device = device
+	return m
+}
+
+// The UnpairDevicesFailed event.
+type UnpairDevicesFailed struct {
+	*Message
+	Payload bluetoothDevices `json:"payload"`
+}
+
+func NewUnpairDevicesFailed(messageId string, requester BluetoothRequester, devices []BluetoothDevice) *UnpairDevicesFailed {
+	m := new(UnpairDevicesFailed)
+	m.Message = newEvent("Bluetooth", "UnpairDevicesFailed", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.Devices = devices
+	return m
+}
+
+// The UnpairDevicesSucceeded event.
+type UnpairDevicesSucceeded struct {
+	*Message
+	Payload bluetoothDevices `json:"payload"`
+}
+
+func NewUnpairDevicesSucceeded(messageId string, requester BluetoothRequester, devices []BluetoothDevice) *UnpairDevicesSucceeded {
+	m := new(UnpairDevicesSucceeded)
+	m.Message = newEvent("Bluetooth", "UnpairDevicesSucceeded", messageId, "")
+	m.Payload.Requester = requester
+	m.Payload.Devices = devices
+	return m
+}
+
+/********** DoNotDisturb **********/
+
+// The DoNotDisturbChanged event.
+type DoNotDisturbChanged struct {
+	*Message
+	Payload struct {
+		Enabled bool `json:"enabled"`
+	} `json:"payload"`
+}
+
+func NewDoNotDisturbChanged(messageId string, enabled bool) *DoNotDisturbChanged {
+	m := new(DoNotDisturbChanged)
+	m.Message = newEvent("DoNotDisturb", "DoNotDisturbChanged", messageId, "")
+	m.Payload.Enabled = enabled
+	return m
+}
+
+// The ReportDoNotDisturb event.
+type ReportDoNotDisturb struct {
+	*Message
+	Payload struct {
+		Enabled bool `json:"enabled"`
+	} `json:"payload"`
+}
+
+func NewReportDoNotDisturb(messageId string, enabled bool) *ReportDoNotDisturb {
+	m := new(ReportDoNotDisturb)
+	m.Message = newEvent("DoNotDisturb", "ReportDoNotDisturb", messageId, "")
+	m.Payload.Enabled = enabled
+	return m
+}
+
+/********** EqualizerController **********/
+
+// The EqualizerChanged event.
+type EqualizerChanged struct {
+	*Message
+	Payload equalizerState `json:"payload"`
+}
+
+func NewEqualizerChanged(messageId string, bands []EqualizerBandLevel, mode EqualizerMode) *EqualizerChanged {
+	m := new(EqualizerChanged)
+	m.Message = newEvent("EqualizerController", "EqualizerChanged", messageId, "")
+	m.Payload.Bands = bands
+	m.Payload.Mode = mode
+	return m
+}
+
 /********** PlaybackController **********/
 
+// PlaybackControllerVersion is a version of the PlaybackController interface.
+type PlaybackControllerVersion string
+
+// Possible values for PlaybackControllerVersion.
+const (
+	// PlaybackControllerVersion10 has an event for each of the play, pause, next
+	// and previous buttons, and no toggles.
+	PlaybackControllerVersion10 = PlaybackControllerVersion("1.0")
+	// PlaybackControllerVersion11 has the ButtonCommandIssued and
+	// ToggleCommandIssued events.
+	PlaybackControllerVersion11 = PlaybackControllerVersion("1.1")
+)
+
+// NewPlaybackButtonEvent returns the event for a press of the button in the
+// provided version of the PlaybackController interface. Version 1.0 only
+// supports the play, pause, next and previous buttons.
+func NewPlaybackButtonEvent(version PlaybackControllerVersion, messageId string, button PlaybackButton) (TypedMessage, error) {
+	if version != PlaybackControllerVersion10 {
+		return NewButtonCommandIssued(messageId, button), nil
+	}
+	switch button {
+	case PlaybackButtonPlay:
+		return NewPlayCommandIssued(messageId), nil
+	case PlaybackButtonPause:
+		return NewPauseCommandIssued(messageId), nil
+	case PlaybackButtonNext:
+		return NewNextCommandIssued(messageId), nil
+	case PlaybackButtonPrevious:
+		return NewPreviousCommandIssued(messageId), nil
+	}
+	return nil, fmt.Errorf("button %s is not supported by PlaybackController %s", button, version)
+}
+
+// The ButtonCommandIssued event.
+type ButtonCommandIssued struct {
+	*Message
+	Payload struct {
+		Name PlaybackButton `json:"name"`
+	} `json:"payload"`
+}
+
+func NewButtonCommandIssued(messageId string, button PlaybackButton) *ButtonCommandIssued {
+	m := new(ButtonCommandIssued)
+	m.Message = newEvent("PlaybackController", "ButtonCommandIssued", messageId, "")
+	m.Payload.Name = button
+	return m
+}
+
 // The NextCommandIssued event.
 type NextCommandIssued struct {
 	*Message
@@ -423,6 +869,23 @@ func NewPreviousCommandIssued(messageId string) *PreviousCommandIssued {
 	return m
 }
 
+// The ToggleCommandIssued event.
+type ToggleCommandIssued struct {
+	*Message
+	Payload struct {
+		Name   PlaybackToggle `json:"name"`
+		Action ToggleAction   `json:"action"`
+	} `json:"payload"`
+}
+
+func NewToggleCommandIssued(messageId string, toggle PlaybackToggle, action ToggleAction) *ToggleCommandIssued {
+	m := new(ToggleCommandIssued)
+	m.Message = newEvent("PlaybackController", "ToggleCommandIssued", messageId, "")
+	m.Payload.Name = toggle
+	m.Payload.Action = action
+	return m
+}
+
 /********** Speaker **********/
 
 // The MuteChanged event.
@@ -485,12 +948,73 @@ const (
 	RecognizeProfileFarField  = RecognizeProfile("FAR_FIELD")
 )
 
+// RecognizeFormat identifies the encoding of the audio sent with Recognize.
+type RecognizeFormat string
+
+// Possible values for RecognizeFormat.
+const (
+	// RecognizeFormatL16 is 16 kHz, mono, 16-bit little-endian PCM.
+	RecognizeFormatL16 = RecognizeFormat("AUDIO_L16_RATE_16000_CHANNELS_1")
+	// RecognizeFormatOpus is a sequence of Opus packets encoded at 16 kHz, mono,
+	// 32 kbps CBR with 20 ms frames.
+	RecognizeFormatOpus = RecognizeFormat("OPUS")
+)
+
+// RecognizeInitiatorType identifies how the user started an interaction.
+type RecognizeInitiatorType string
+
+// Possible values for RecognizeInitiatorType.
+const (
+	RecognizeInitiatorPressAndHold = RecognizeInitiatorType("PRESS_AND_HOLD")
+	RecognizeInitiatorTap          = RecognizeInitiatorType("TAP")
+	RecognizeInitiatorWakeWord     = RecognizeInitiatorType("WAKEWORD")
+)
+
+// RecognizeInitiator describes how an interaction was started by the user.
+// Initiators received with ExpectSpeech directives are kept as raw JSON
+// instead, since they must be sent back unchanged.
+type RecognizeInitiator struct {
+	Type    RecognizeInitiatorType `json:"type,omitempty"`
+	Payload struct {
+		WakeWordIndices *WakeWordIndices `json:"wakeWordIndices,omitempty"`
+		WakeWord        string           `json:"wakeWord,omitempty"`
+		Token           string           `json:"token,omitempty"`
+	} `json:"payload"`
+}
+
+// Returns the initiator encoded as JSON, or nil if it's nil.
+func (i *RecognizeInitiator) raw() json.RawMessage {
+	if i == nil {
+		return nil
+	}
+	// This can't fail since the initiator only contains strings and numbers.
+	data, _ := json.Marshal(i)
+	return data
+}
+
+// WakeWordIndices locates the wake word in the audio of a Recognize event, in
+// samples from the start of the audio.
+type WakeWordIndices struct {
+	StartIndexInSamples int64 `json:"startIndexInSamples"`
+	EndIndexInSamples   int64 `json:"endIndexInSamples"`
+}
+
+// NewWakeWordInitiator returns the initiator for audio which contains the wake
+// word between the start and end sample indices.
+func NewWakeWordInitiator(wakeWord string, start, end int64) *RecognizeInitiator {
+	i := &RecognizeInitiator{Type: RecognizeInitiatorWakeWord}
+	i.Payload.WakeWord = wakeWord
+	i.Payload.WakeWordIndices = &WakeWordIndices{start, end}
+	return i
+}
+
 // The Recognize event.
 type Recognize struct {
 	*Message
 	Payload struct {
-		Profile RecognizeProfile `json:"profile"`
-		Format  string           `json:"format"`
+		Profile   RecognizeProfile `json:"profile"`
+		Format    string           `json:"format"`
+		Initiator json.RawMessage  `json:"initiator,omitempty"`
 	} `json:"payload"`
 }
 
@@ -499,10 +1023,28 @@ func NewRecognize(messageId, dialogRequestId string) *Recognize {
 }
 
 func NewRecognizeWithProfile(messageId, dialogRequestId string, profile RecognizeProfile) *Recognize {
+	return NewRecognizeWithFormat(messageId, dialogRequestId, profile, RecognizeFormatL16)
+}
+
+func NewRecognizeWithFormat(messageId, dialogRequestId string, profile RecognizeProfile, format RecognizeFormat) *Recognize {
+	return NewRecognizeWithInitiator(messageId, dialogRequestId, profile, format, nil)
+}
+
+// NewRecognizeWithInitiator returns a Recognize event which tells AVS how the
+// interaction was started. The initiator may be nil.
+func NewRecognizeWithInitiator(messageId, dialogRequestId string, profile RecognizeProfile, format RecognizeFormat, initiator *RecognizeInitiator) *Recognize {
+	return NewRecognizeWithRawInitiator(messageId, dialogRequestId, profile, format, initiator.raw())
+}
+
+// NewRecognizeWithRawInitiator returns a Recognize event with an initiator
+// that is sent exactly as provided, such as the initiator of an ExpectSpeech
+// directive. The initiator may be nil.
+func NewRecognizeWithRawInitiator(messageId, dialogRequestId string, profile RecognizeProfile, format RecognizeFormat, initiator json.RawMessage) *Recognize {
 	m := new(Recognize)
 	m.Message = newEvent("SpeechRecognizer", "Recognize", messageId, dialogRequestId)
-	m.Payload.Format = "AUDIO_L16_RATE_16000_CHANNELS_1"
+	m.Payload.Format = string(format)
 	m.Payload.Profile = profile
+	m.Payload.Initiator = initiator
 	return m
 }
 
@@ -553,12 +1095,32 @@ type SettingsUpdated struct {
 	} `json:"payload"`
 }
 
+// SettingLocale is a locale supported by AVS (see SupportedLocales).
 type SettingLocale string
+
 // Possible values for SettingLocale.
 const (
-	SettingLocaleUS = SettingLocale("en-US")
-	SettingLocaleGB = SettingLocale("en-GB")
-	SettingLocaleDE = SettingLocale("de-DE")
+	SettingLocaleArSA = SettingLocale("ar-SA")
+	SettingLocaleDeDE = SettingLocale("de-DE")
+	SettingLocaleEnAU = SettingLocale("en-AU")
+	SettingLocaleEnCA = SettingLocale("en-CA")
+	SettingLocaleEnGB = SettingLocale("en-GB")
+	SettingLocaleEnIN = SettingLocale("en-IN")
+	SettingLocaleEnUS = SettingLocale("en-US")
+	SettingLocaleEsES = SettingLocale("es-ES")
+	SettingLocaleEsMX = SettingLocale("es-MX")
+	SettingLocaleEsUS = SettingLocale("es-US")
+	SettingLocaleFrCA = SettingLocale("fr-CA")
+	SettingLocaleFrFR = SettingLocale("fr-FR")
+	SettingLocaleHiIN = SettingLocale("hi-IN")
+	SettingLocaleItIT = SettingLocale("it-IT")
+	SettingLocaleJaJP = SettingLocale("ja-JP")
+	SettingLocalePtBR = SettingLocale("pt-BR")
+
+	// The original names of the en-US, en-GB and de-DE locales.
+	SettingLocaleUS = SettingLocaleEnUS
+	SettingLocaleGB = SettingLocaleEnGB
+	SettingLocaleDE = SettingLocaleDeDE
 )
 
 func NewLocaleSettingsUpdated(messageId string, locale SettingLocale) *SettingsUpdated {
@@ -594,6 +1156,36 @@ func NewExceptionEncountered(messageId, directive string, errorType ErrorType, e
 	return m
 }
 
+// The LocalesChanged event.
+type LocalesChanged struct {
+	*Message
+	Payload struct {
+		Locales []SettingLocale `json:"locales"`
+	} `json:"payload"`
+}
+
+func NewLocalesChanged(messageId string, locales []SettingLocale) *LocalesChanged {
+	m := new(LocalesChanged)
+	m.Message = newEvent("System", "LocalesChanged", messageId, "")
+	m.Payload.Locales = locales
+	return m
+}
+
+// The SoftwareInfo event.
+type SoftwareInfo struct {
+	*Message
+	Payload struct {
+		FirmwareVersion string `json:"firmwareVersion"`
+	} `json:"payload"`
+}
+
+func NewSoftwareInfo(messageId, firmwareVersion string) *SoftwareInfo {
+	m := new(SoftwareInfo)
+	m.Message = newEvent("System", "SoftwareInfo", messageId, "")
+	m.Payload.FirmwareVersion = firmwareVersion
+	return m
+}
+
 // The SynchronizeState event.
 type SynchronizeState struct {
 	*Message
@@ -606,6 +1198,21 @@ func NewSynchronizeState(messageId string) *SynchronizeState {
 	return m
 }
 
+// The TimeZoneChanged event.
+type TimeZoneChanged struct {
+	*Message
+	Payload struct {
+		TimeZone string `json:"timeZone"`
+	} `json:"payload"`
+}
+
+func NewTimeZoneChanged(messageId, timeZone string) *TimeZoneChanged {
+	m := new(TimeZoneChanged)
+	m.Message = newEvent("System", "TimeZoneChanged", messageId, "")
+	m.Payload.TimeZone = timeZone
+	return m
+}
+
 // The UserInactivityReport event.
 type UserInactivityReport struct {
 	*Message
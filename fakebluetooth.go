@@ -0,0 +1,188 @@
+package avs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeBluetoothAdapter is a BluetoothAdapter without a radio, for testing the
+// Bluetooth flows on machines without Bluetooth. Devices in Nearby can be
+// paired, and paired devices can be connected.
+type FakeBluetoothAdapter struct {
+	Name string
+	// The devices that a scan finds.
+	Nearby []BluetoothDevice
+	// Err is optional. If it's set, every operation except FriendlyName and
+	// PairedDevices fails with it.
+	Err error
+
+	mu           sync.Mutex
+	paired       []BluetoothDevice
+	connected    string
+	discoverable time.Time
+	commands     []BluetoothMediaCommand
+}
+
+// NewFakeBluetoothAdapter returns a FakeBluetoothAdapter with the provided
+// nearby devices.
+func NewFakeBluetoothAdapter(name string, nearby ...BluetoothDevice) *FakeBluetoothAdapter {
+	return &FakeBluetoothAdapter{Name: name, Nearby: nearby}
+}
+
+// FriendlyName implements the BluetoothAdapter interface.
+func (a *FakeBluetoothAdapter) FriendlyName() string {
+	return a.Name
+}
+
+// PairedDevices implements the BluetoothAdapter interface.
+func (a *FakeBluetoothAdapter) PairedDevices() ([]BluetoothDevice, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]BluetoothDevice{}, a.paired...), nil
+}
+
+// Scan implements the BluetoothAdapter interface. Devices that are already
+// paired aren't returned.
+func (a *FakeBluetoothAdapter) Scan() ([]BluetoothDevice, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Err != nil {
+		return nil, a.Err
+	}
+	devices := []BluetoothDevice{}
+	for _, device := range a.Nearby {
+		if a.pairedIndex(device.UniqueDeviceId) < 0 {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+// SetDiscoverable implements the BluetoothAdapter interface.
+func (a *FakeBluetoothAdapter) SetDiscoverable(discoverable bool, duration time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Err != nil {
+		return a.Err
+	}
+	a.discoverable = time.Time{}
+	if discoverable {
+		a.discoverable = time.Now().Add(duration)
+	}
+	return nil
+}
+
+// Discoverable returns whether the adapter is currently discoverable.
+func (a *FakeBluetoothAdapter) Discoverable() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return time.Now().Before(a.discoverable)
+}
+
+// Pair implements the BluetoothAdapter interface.
+func (a *FakeBluetoothAdapter) Pair(id string) (BluetoothDevice, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Err != nil {
+		return BluetoothDevice{}, a.Err
+	}
+	if i := a.pairedIndex(id); i >= 0 {
+		return a.paired[i], nil
+	}
+	for _, device := range a.Nearby {
+		if device.UniqueDeviceId == id {
+			a.paired = append(a.paired, device)
+			return device, nil
+		}
+	}
+	return BluetoothDevice{}, fmt.Errorf("bluetooth device %s is not nearby", id)
+}
+
+// Unpair implements the BluetoothAdapter interface.
+func (a *FakeBluetoothAdapter) Unpair(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Err != nil {
+		return a.Err
+	}
+	i := a.pairedIndex(id)
+	if i < 0 {
+		return fmt.Errorf("bluetooth device %s is not paired", id)
+	}
+	a.paired = append(a.paired[:i], a.paired[i+1:]...)
+	if a.connected == id {
+		a.connected = ""
+	}
+	return nil
+}
+
+// Connect implements the BluetoothAdapter interface.
+func (a *FakeBluetoothAdapter) Connect(id string) (BluetoothDevice, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Err != nil {
+		return BluetoothDevice{}, a.Err
+	}
+	i := a.pairedIndex(id)
+	if i < 0 {
+		return BluetoothDevice{}, fmt.Errorf("bluetooth device %s is not paired", id)
+	}
+	a.connected = id
+	return a.paired[i], nil
+}
+
+// Disconnect implements the BluetoothAdapter interface.
+func (a *FakeBluetoothAdapter) Disconnect(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Err != nil {
+		return a.Err
+	}
+	if a.connected != id {
+		return fmt.Errorf("bluetooth device %s is not connected", id)
+	}
+	a.connected = ""
+	return nil
+}
+
+// Connected returns the id of the connected device, or an empty string.
+func (a *FakeBluetoothAdapter) Connected() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.connected
+}
+
+// MediaControl implements the BluetoothAdapter interface. The commands are
+// recorded and can be inspected with Commands.
+func (a *FakeBluetoothAdapter) MediaControl(id string, command BluetoothMediaCommand) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Err != nil {
+		return a.Err
+	}
+	if a.connected != id {
+		return fmt.Errorf("bluetooth device %s is not connected", id)
+	}
+	a.commands = append(a.commands, command)
+	return nil
+}
+
+// Commands returns the media commands that have been sent, in order.
+func (a *FakeBluetoothAdapter) Commands() []BluetoothMediaCommand {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]BluetoothMediaCommand{}, a.commands...)
+}
+
+// Returns the index of the paired device with the id, or -1.
+//
+// This method must be called with a.mu held.
+func (a *FakeBluetoothAdapter) pairedIndex(id string) int {
+	for i, device := range a.paired {
+		if device.UniqueDeviceId == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,168 @@
+package avs
+
+import (
+	"sync"
+)
+
+// FocusChannel identifies one of the audio channels that activities on the
+// device compete for.
+type FocusChannel int
+
+// Possible values for FocusChannel, in order of priority.
+const (
+	// FocusChannelDialog is used for speech (Speak directives and the
+	// microphone). It has the highest priority.
+	FocusChannelDialog FocusChannel = iota
+	// FocusChannelAlerts is used for alarms and timers.
+	FocusChannelAlerts
+	// FocusChannelNotifications is used for the audio indicator of
+	// notifications, which must not interrupt alerts.
+	FocusChannelNotifications
+	// FocusChannelContent is used for AudioPlayer content. It has the lowest
+	// priority.
+	FocusChannelContent
+	numFocusChannels
+)
+
+// FocusState specifies how an activity is allowed to use its audio channel.
+type FocusState string
+
+// Possible values for FocusState.
+const (
+	// FocusStateForeground means that the activity may play audio normally.
+	FocusStateForeground = FocusState("FOREGROUND")
+	// FocusStateBackground means that a higher priority channel is active, so
+	// the activity should pause or duck its audio.
+	FocusStateBackground = FocusState("BACKGROUND")
+	// FocusStateNone means that the activity lost its channel and should stop.
+	FocusStateNone = FocusState("NONE")
+)
+
+// FocusObserver is notified when the focus of an activity changes.
+//
+// Activities are identified by their FocusObserver value, so implementations
+// must be comparable (e.g., pointers to structs).
+type FocusObserver interface {
+	FocusChanged(state FocusState) error
+}
+
+// FocusManager grants audio channels to activities. Only the activity on the
+// highest priority channel is in the foreground; all other activities are in
+// the background until the channels above them are released.
+type FocusManager struct {
+	mu      sync.Mutex
+	holders [numFocusChannels]FocusObserver
+	states  [numFocusChannels]FocusState
+}
+
+// NewFocusManager returns a FocusManager with all channels released.
+func NewFocusManager() *FocusManager {
+	return new(FocusManager)
+}
+
+// Acquire grants the channel to the provided activity. Any activity that was
+// previously holding the channel loses its focus. The focus of all affected
+// activities (including the new one) is updated before Acquire returns.
+//
+// Acquire returns the first error returned by a notified observer.
+func (m *FocusManager) Acquire(channel FocusChannel, activity FocusObserver) error {
+	m.mu.Lock()
+	var changes []focusChange
+	if previous := m.holders[channel]; previous != nil && previous != activity {
+		changes = append(changes, focusChange{previous, FocusStateNone})
+		m.states[channel] = ""
+	}
+	m.holders[channel] = activity
+	changes = append(changes, m.update()...)
+	m.mu.Unlock()
+	return notifyFocusChanges(changes)
+}
+
+// Release releases the channel if it's held by the provided activity, which
+// will be notified that it no longer has focus. Activities on lower priority
+// channels may move to the foreground as a result.
+func (m *FocusManager) Release(channel FocusChannel, activity FocusObserver) error {
+	m.mu.Lock()
+	if m.holders[channel] != activity {
+		m.mu.Unlock()
+		return nil
+	}
+	changes := []focusChange{{activity, FocusStateNone}}
+	m.holders[channel] = nil
+	m.states[channel] = ""
+	changes = append(changes, m.update()...)
+	m.mu.Unlock()
+	return notifyFocusChanges(changes)
+}
+
+// State returns the focus state of the channel, which will be FocusStateNone
+// if it's not held by any activity.
+func (m *FocusManager) State(channel FocusChannel) FocusState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holders[channel] == nil {
+		return FocusStateNone
+	}
+	return m.states[channel]
+}
+
+// Recalculates the state of every held channel and returns the changes.
+//
+// This method must be called with m.mu held.
+func (m *FocusManager) update() []focusChange {
+	var changes []focusChange
+	state := FocusStateForeground
+	for channel, holder := range m.holders {
+		if holder == nil {
+			continue
+		}
+		if m.states[channel] != state {
+			m.states[channel] = state
+			changes = append(changes, focusChange{holder, state})
+		}
+		state = FocusStateBackground
+	}
+	return changes
+}
+
+type focusChange struct {
+	observer FocusObserver
+	state    FocusState
+}
+
+func notifyFocusChanges(changes []focusChange) error {
+	var err error
+	for _, c := range changes {
+		if e := c.observer.FocusChanged(c.state); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// AlertFocus is a FocusObserver for an active alert. It reports the
+// AlertEnteredForeground and AlertEnteredBackground events to AVS when the
+// focus of the alert changes, and then calls OnChange (if set) so that the
+// alert audio can be adjusted.
+type AlertFocus struct {
+	Sender   EventSender
+	Token    string
+	OnChange func(state FocusState) error
+}
+
+// FocusChanged implements the FocusObserver interface.
+func (a *AlertFocus) FocusChanged(state FocusState) error {
+	var err error
+	switch state {
+	case FocusStateForeground:
+		_, err = a.Sender.SendEvent(NewAlertEnteredForeground(RandomUUIDString(), a.Token))
+	case FocusStateBackground:
+		_, err = a.Sender.SendEvent(NewAlertEnteredBackground(RandomUUIDString(), a.Token))
+	}
+	if a.OnChange != nil {
+		if e := a.OnChange(state); err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package avs
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserInactivityReportInterval is how often AVS expects a UserInactivityReport.
+const UserInactivityReportInterval = time.Hour
+
+// InactivityStore persists the time of the last user activity so that it
+// survives restarts.
+type InactivityStore interface {
+	// LoadLastActivity returns the persisted time, or the zero time if nothing
+	// has been persisted yet.
+	LoadLastActivity() (time.Time, error)
+	SaveLastActivity(t time.Time) error
+}
+
+// FileInactivityStore is an InactivityStore that keeps the time of the last
+// user activity in a file.
+type FileInactivityStore string
+
+// LoadLastActivity implements the InactivityStore interface.
+func (path FileInactivityStore) LoadLastActivity() (time.Time, error) {
+	data, err := ioutil.ReadFile(string(path))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+}
+
+// SaveLastActivity implements the InactivityStore interface.
+func (path FileInactivityStore) SaveLastActivity(t time.Time) error {
+	return ioutil.WriteFile(string(path), []byte(t.Format(time.RFC3339Nano)), 0666)
+}
+
+// UserInactivity keeps track of when the user last interacted with the device
+// and periodically reports the UserInactivityReport event to AVS.
+type UserInactivity struct {
+	Sender EventSender
+	Clock  Clock
+	// Store is optional. If it's nil, the last activity is only kept in memory.
+	Store InactivityStore
+	// ErrorHandler is optional. It's called with the errors of the reports
+	// that Run sends.
+	ErrorHandler func(err error)
+
+	mu           sync.Mutex
+	lastActivity time.Time
+}
+
+// NewUserInactivity returns a UserInactivity which uses the provided clock and
+// restores the time of the last user activity from the provided store (which
+// may be nil). If no activity has been recorded, the user is considered active
+// as of now, and that time is persisted so that it isn't reset by a restart.
+func NewUserInactivity(sender EventSender, clock Clock, store InactivityStore) (*UserInactivity, error) {
+	u := &UserInactivity{
+		Sender: sender,
+		Clock:  clock,
+		Store:  store,
+	}
+	if store != nil {
+		t, err := store.LoadLastActivity()
+		if err != nil {
+			return nil, err
+		}
+		u.lastActivity = t
+	}
+	if u.lastActivity.IsZero() {
+		if err := u.Activity(); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// Activity records that the user interacted with the device just now.
+func (u *UserInactivity) Activity() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.lastActivity = u.Clock.Now()
+	if u.Store == nil {
+		return nil
+	}
+	return u.Store.SaveLastActivity(u.lastActivity)
+}
+
+// RecordEvent records user activity if the event is one that the user
+// initiated, such as Recognize or a PlaybackController command.
+func (u *UserInactivity) RecordEvent(event TypedMessage) error {
+	m := event.GetMessage()
+	if m.String() == "SpeechRecognizer.Recognize" ||
+		(m.Header["namespace"] == "PlaybackController" && strings.HasSuffix(m.Header["name"], "CommandIssued")) {
+		return u.Activity()
+	}
+	return nil
+}
+
+// ResetUserInactivity applies the ResetUserInactivity directive.
+func (u *UserInactivity) ResetUserInactivity(d *ResetUserInactivity) error {
+	return u.Activity()
+}
+
+// InactiveTime returns how long it's been since the last user activity.
+func (u *UserInactivity) InactiveTime() time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.Clock.Now().Sub(u.lastActivity)
+}
+
+// Report sends a UserInactivityReport event with the current inactive time.
+func (u *UserInactivity) Report() error {
+	_, err := u.Sender.SendEvent(NewUserInactivityReport(RandomUUIDString(), u.InactiveTime()))
+	return err
+}
+
+// Run sends a UserInactivityReport event every hour until done is closed.
+// Reports that fail to send are passed to the ErrorHandler but not retried,
+// since the next report will carry the up-to-date inactive time.
+func (u *UserInactivity) Run(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-u.Clock.After(UserInactivityReportInterval):
+			if err := u.Report(); err != nil && u.ErrorHandler != nil {
+				u.ErrorHandler(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package avs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+	// Receives a value whenever After is called.
+	waiting chan struct{}
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+		waiting: make(chan struct{}, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := fakeTimer{c.now.Add(d), make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	c.waiting <- struct{}{}
+	return timer.c
+}
+
+// Advance moves the time forward and fires the timers that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.timers = pending
+}
+
+// Wait blocks until After has been called.
+func (c *fakeClock) Wait(t *testing.T) {
+	select {
+	case <-c.waiting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a timer")
+	}
+}
+
+// An InactivityStore which keeps the time in memory.
+type memoryInactivityStore struct {
+	t time.Time
+}
+
+func (s *memoryInactivityStore) LoadLastActivity() (time.Time, error) {
+	return s.t, nil
+}
+
+func (s *memoryInactivityStore) SaveLastActivity(t time.Time) error {
+	s.t = t
+	return nil
+}
+
+// Returns an EventSender which sends the inactive time of each
+// UserInactivityReport to the channel.
+func inactivityReports(reports chan<- int) EventSender {
+	return EventSenderFunc(func(event TypedMessage) (*Response, error) {
+		if report, ok := event.(*UserInactivityReport); ok {
+			reports <- report.Payload.InactiveTimeInSeconds
+		}
+		return nil, nil
+	})
+}
+
+func TestUserInactivity(t *testing.T) {
+	clock := newFakeClock()
+	store := new(memoryInactivityStore)
+	reports := make(chan int, 10)
+	u, err := NewUserInactivity(inactivityReports(reports), clock, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The user is considered active as of when the device first started.
+	if !store.t.Equal(clock.Now()) {
+		t.Errorf("got %s persisted, want %s", store.t, clock.Now())
+	}
+	clock.Advance(90 * time.Second)
+	if got := u.InactiveTime(); got != 90*time.Second {
+		t.Errorf("got inactive time %s, want 90s", got)
+	}
+	if err := u.ResetUserInactivity(&ResetUserInactivity{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.InactiveTime(); got != 0 {
+		t.Errorf("got inactive time %s after ResetUserInactivity, want 0s", got)
+	}
+	if !store.t.Equal(clock.Now()) {
+		t.Errorf("got %s persisted, want %s", store.t, clock.Now())
+	}
+	// The time of the last activity survives a restart.
+	clock.Advance(time.Minute)
+	restarted, err := NewUserInactivity(inactivityReports(reports), clock, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.InactiveTime(); got != time.Minute {
+		t.Errorf("got inactive time %s after restarting, want 1m0s", got)
+	}
+}
+
+func TestUserInactivityRecordEvent(t *testing.T) {
+	clock := newFakeClock()
+	u, err := NewUserInactivity(inactivityReports(make(chan int, 10)), clock, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		event  TypedMessage
+		active bool
+	}{
+		{NewRecognize(RandomUUIDString(), RandomUUIDString()), true},
+		{NewPlayCommandIssued(RandomUUIDString()), true},
+		{NewSynchronizeState(RandomUUIDString()), false},
+		{NewPlaybackStarted(RandomUUIDString(), "token", 0), false},
+	}
+	for _, test := range tests {
+		clock.Advance(time.Minute)
+		if err := u.RecordEvent(test.event); err != nil {
+			t.Fatal(err)
+		}
+		if active := u.InactiveTime() == 0; active != test.active {
+			t.Errorf("%s: got activity %v, want %v", test.event.GetMessage(), active, test.active)
+		}
+	}
+}
+
+func TestUserInactivityRun(t *testing.T) {
+	clock := newFakeClock()
+	reports := make(chan int, 10)
+	u, err := NewUserInactivity(inactivityReports(reports), clock, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go u.Run(done)
+	clock.Wait(t)
+	// Nothing is reported before the interval has passed.
+	clock.Advance(UserInactivityReportInterval - time.Second)
+	select {
+	case <-reports:
+		t.Fatal("got a report before the interval")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	if got := <-reports; got != 3600 {
+		t.Errorf("got %d seconds in the first report, want 3600", got)
+	}
+	clock.Wait(t)
+	u.Activity()
+	clock.Advance(UserInactivityReportInterval)
+	if got := <-reports; got != 3600 {
+		t.Errorf("got %d seconds after activity, want 3600", got)
+	}
+	clock.Wait(t)
+	clock.Advance(UserInactivityReportInterval)
+	if got := <-reports; got != 7200 {
+		t.Errorf("got %d seconds in the third report, want 7200", got)
+	}
+}
+
+func TestUserInactivityRunError(t *testing.T) {
+	clock := newFakeClock()
+	sendErr := errors.New("offline")
+	u, err := NewUserInactivity(EventSenderFunc(func(event TypedMessage) (*Response, error) {
+		return nil, sendErr
+	}), clock, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 1)
+	u.ErrorHandler = func(err error) { errs <- err }
+	done := make(chan struct{})
+	defer close(done)
+	go u.Run(done)
+	clock.Wait(t)
+	clock.Advance(UserInactivityReportInterval)
+	if err := <-errs; err != sendErr {
+		t.Errorf("got error %v, want %v", err, sendErr)
+	}
+}
+
+func TestFileInactivityStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := FileInactivityStore(filepath.Join(dir, "activity"))
+	if got, err := store.LoadLastActivity(); err != nil || !got.IsZero() {
+		t.Errorf("got %s, %v before saving; want the zero time", got, err)
+	}
+	want := time.Date(2017, 6, 1, 12, 30, 15, 500, time.UTC)
+	if err := store.SaveLastActivity(want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.LoadLastActivity(); err != nil || !got.Equal(want) {
+		t.Errorf("got %s, %v; want %s", got, err, want)
+	}
+}
@@ -50,6 +50,38 @@ func (m *Message) Typed() TypedMessage {
 		return fill(new(Play), m)
 	case "AudioPlayer.Stop":
 		return fill(new(Stop), m)
+	case "Bluetooth.ConnectByDeviceIds":
+		return fill(new(ConnectByDeviceIds), m)
+	case "Bluetooth.ConnectByProfile":
+		return fill(new(ConnectByProfile), m)
+	case "Bluetooth.DisconnectDevices":
+		return fill(new(DisconnectDevices), m)
+	case "Bluetooth.EnterDiscoverableMode":
+		return fill(new(EnterDiscoverableMode), m)
+	case "Bluetooth.ExitDiscoverableMode":
+		return fill(new(ExitDiscoverableMode), m)
+	case "Bluetooth.Next", "Bluetooth.Play", "Bluetooth.Previous", "Bluetooth.Stop":
+		return fill(new(BluetoothMediaControl), m)
+	case "Bluetooth.PairDevices":
+		return fill(new(PairDevices), m)
+	case "Bluetooth.ScanDevices":
+		return fill(new(ScanDevices), m)
+	case "Bluetooth.UnpairDevices":
+		return fill(new(UnpairDevices), m)
+	case "DoNotDisturb.SetDoNotDisturb":
+		return fill(new(SetDoNotDisturb), m)
+	case "EqualizerController.AdjustBands":
+		return fill(new(AdjustBands), m)
+	case "EqualizerController.ResetBands":
+		return fill(new(ResetBands), m)
+	case "EqualizerController.SetBands":
+		return fill(new(SetBands), m)
+	case "EqualizerController.SetMode":
+		return fill(new(SetMode), m)
+	case "Notifications.ClearIndicator":
+		return fill(new(ClearIndicator), m)
+	case "Notifications.SetIndicator":
+		return fill(new(SetIndicator), m)
 	case "Speaker.AdjustVolume":
 		return fill(new(AdjustVolume), m)
 	case "Speaker.SetMute":
@@ -65,10 +97,22 @@ func (m *Message) Typed() TypedMessage {
 	case "System.Exception":
 		// Exception is not a directive, but may also be sent by AVS.
 		return fill(new(Exception), m)
+	case "System.ReportSoftwareInfo":
+		return fill(new(ReportSoftwareInfo), m)
+	case "System.RevokeAuthorization":
+		return fill(new(RevokeAuthorization), m)
 	case "System.SetEndpoint":
 		return fill(new(SetEndpoint), m)
+	case "System.SetLocales":
+		return fill(new(SetLocales), m)
+	case "System.SetTimeZone":
+		return fill(new(SetTimeZone), m)
 	case "System.ResetUserInactivity":
 		return fill(new(ResetUserInactivity), m)
+	case "TemplateRuntime.RenderPlayerInfo":
+		return fill(new(RenderPlayerInfo), m)
+	case "TemplateRuntime.RenderTemplate":
+		return fill(new(RenderTemplate), m)
 	default:
 		return m
 	}
@@ -0,0 +1,1140 @@
+package avs
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrNoMP3Frames is returned when no MP3 frames could be found in a stream.
+var ErrNoMP3Frames = errors.New("no MP3 frames found")
+
+// The number of frequency lines (and samples) in a granule.
+const mp3GranuleSize = 576
+
+// The size of the bit reservoir. MPEG-1 frames can refer back up to 511 bytes.
+const mp3MaxReservoir = 511
+
+// MP3Decoder decodes MPEG audio layer III (MP3), the format of the audio in
+// Speak directives, to 16-bit little-endian PCM. MPEG-1, MPEG-2 and MPEG-2.5
+// streams are supported, but free format bitrates are not.
+//
+// The audio is decoded as it's read, so the decoder can be used both with a
+// buffered attachment in Response.Content and with a stream.
+type MP3Decoder struct {
+	r      *bufio.Reader
+	format AudioFormat
+	// The header of the first frame. All other frames must have the same
+	// version, sample rate and number of channels.
+	first mp3Header
+	// The frame which has been read (in NewMP3Decoder) but not decoded yet.
+	pending []byte
+	// Main data of previous frames which later frames may refer back to.
+	reservoir []byte
+	// The scalefactors of the current frame, per granule and channel.
+	scalefactors [2][2]mp3Scalefactors
+	// The second half of the last IMDCT output, per channel.
+	overlap [2][mp3GranuleSize]float64
+	// The state of the synthesis filterbank, per channel.
+	v [2][1024]float64
+
+	out []byte
+	err error
+}
+
+// NewMP3Decoder returns a decoder of the MP3 stream. It reads the stream up to
+// the first frame to determine the format of the audio.
+func NewMP3Decoder(r io.Reader) (*MP3Decoder, error) {
+	d := &MP3Decoder{r: bufio.NewReader(r)}
+	if err := d.skipID3(); err != nil {
+		return nil, err
+	}
+	header, frame, err := d.readFrame()
+	if err == io.EOF {
+		return nil, ErrNoMP3Frames
+	} else if err != nil {
+		return nil, err
+	}
+	d.first = header
+	d.pending = frame
+	d.format = AudioFormat{
+		SampleRate:    header.sampleRate(),
+		Channels:      header.channels(),
+		BitsPerSample: 16,
+	}
+	return d, nil
+}
+
+// Format returns the format of the decoded audio.
+func (d *MP3Decoder) Format() AudioFormat {
+	return d.format
+}
+
+// Read reads decoded audio, with the samples of the channels interleaved.
+func (d *MP3Decoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		frame := d.pending
+		d.pending = nil
+		if frame == nil {
+			var header mp3Header
+			header, frame, d.err = d.readFrame()
+			if d.err != nil {
+				continue
+			}
+			if !header.compatible(d.first) {
+				// The format of the audio can't change in the middle of the stream.
+				continue
+			}
+		}
+		d.decodeFrame(frame)
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// Skips an ID3v2 tag at the start of the stream.
+func (d *MP3Decoder) skipID3() error {
+	header, err := d.r.Peek(10)
+	if err != nil || string(header[:3]) != "ID3" {
+		return nil
+	}
+	size := int(header[6]&0x7f)<<21 | int(header[7]&0x7f)<<14 | int(header[8]&0x7f)<<7 | int(header[9]&0x7f)
+	size += 10
+	if header[5]&0x10 != 0 {
+		// The tag has a footer.
+		size += 10
+	}
+	_, err = d.r.Discard(size)
+	if err == io.EOF {
+		return ErrNoMP3Frames
+	}
+	return err
+}
+
+// Finds the next frame and returns its header and the frame (including the
+// header).
+func (d *MP3Decoder) readFrame() (mp3Header, []byte, error) {
+	for {
+		b, err := d.r.Peek(4)
+		if err != nil {
+			if err == io.ErrUnexpectedEOF || len(b) < 4 {
+				err = io.EOF
+			}
+			return mp3Header{}, nil, err
+		}
+		header, ok := parseMP3Header(b)
+		if !ok || (d.first.bitrate != 0 && !header.compatible(d.first)) {
+			// Not a frame header; keep looking for one.
+			d.r.Discard(1)
+			continue
+		}
+		frame := make([]byte, header.frameSize())
+		if _, err := io.ReadFull(d.r, frame); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return mp3Header{}, nil, err
+		}
+		return header, frame, nil
+	}
+}
+
+// Decodes a frame and appends the audio to d.out.
+func (d *MP3Decoder) decodeFrame(frame []byte) {
+	header, _ := parseMP3Header(frame)
+	channels := header.channels()
+	pos := 4
+	if header.crc {
+		pos += 2
+	}
+	sideInfoEnd := pos + header.sideInfoSize()
+	if sideInfoEnd > len(frame) {
+		return
+	}
+	if tag := string(frame[sideInfoEnd:minInt(sideInfoEnd+4, len(frame))]); tag == "Xing" || tag == "Info" {
+		// The first frame of a VBR file may hold information about the file
+		// instead of audio.
+		return
+	}
+	side := parseMP3SideInfo(header, &mp3BitReader{data: frame[pos:sideInfoEnd]})
+	mainData := frame[sideInfoEnd:]
+	if side.mainDataBegin > len(d.reservoir) {
+		// The frame refers to data that was never received, which happens when
+		// decoding starts in the middle of a stream.
+		d.fillReservoir(mainData)
+		return
+	}
+	data := make([]byte, 0, side.mainDataBegin+len(mainData))
+	data = append(data, d.reservoir[len(d.reservoir)-side.mainDataBegin:]...)
+	data = append(data, mainData...)
+	d.fillReservoir(mainData)
+
+	bits := &mp3BitReader{data: data}
+	samples := make([]int16, header.granules()*mp3GranuleSize*channels)
+	for gr := 0; gr < header.granules(); gr++ {
+		var xr [2][mp3GranuleSize]float64
+		var nonzero [2]int
+		for ch := 0; ch < channels; ch++ {
+			g := &side.granules[gr][ch]
+			start := bits.pos
+			if header.lsf() {
+				d.readLSFScalefactors(header, bits, g, ch)
+			} else {
+				d.readScalefactors(bits, g, gr, ch, side.scfsi[ch])
+			}
+			var values [mp3GranuleSize]int
+			nonzero[ch] = readMP3Huffman(header, bits, g, start+g.part23Length, &values)
+			bits.pos = start + g.part23Length
+			d.requantize(header, g, &d.scalefactors[gr][ch], &values, &xr[ch])
+		}
+		if header.mode == mp3ModeJointStereo {
+			d.stereo(header, &side.granules[gr][1], &d.scalefactors[gr][1], &xr, nonzero)
+		}
+		for ch := 0; ch < channels; ch++ {
+			g := &side.granules[gr][ch]
+			mp3Reorder(header, g, &xr[ch])
+			mp3Antialias(g, &xr[ch])
+			d.hybrid(g, ch, &xr[ch])
+			out := samples[gr*mp3GranuleSize*channels:]
+			d.synthesize(ch, &xr[ch], out[ch:], channels)
+		}
+	}
+	for _, s := range samples {
+		d.out = append(d.out, byte(s), byte(s>>8))
+	}
+}
+
+// Adds main data to the bit reservoir.
+func (d *MP3Decoder) fillReservoir(mainData []byte) {
+	d.reservoir = append(d.reservoir, mainData...)
+	if extra := len(d.reservoir) - mp3MaxReservoir; extra > 0 {
+		d.reservoir = d.reservoir[:copy(d.reservoir, d.reservoir[extra:])]
+	}
+}
+
+/********** Frame header **********/
+
+// Values of the mode field of the frame header.
+const (
+	mp3ModeStereo        = 0
+	mp3ModeJointStereo   = 1
+	mp3ModeDualChannel   = 2
+	mp3ModeSingleChannel = 3
+)
+
+// Values of the version field of the frame header.
+const (
+	mp3Version25 = 0
+	mp3Version2  = 2
+	mp3Version1  = 3
+)
+
+// Bitrates in kbps by version (MPEG-1 or not) and bitrate index.
+var mp3Bitrates = [2][15]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// Sample rates by sample rate index (see mp3Header.rateIndex).
+var mp3SampleRates = [9]int{44100, 48000, 32000, 22050, 24000, 16000, 11025, 12000, 8000}
+
+type mp3Header struct {
+	version int
+	crc     bool
+	bitrate int
+	// The index of the sample rate in mp3SampleRates.
+	rateIndex     int
+	padding       bool
+	mode          int
+	modeExtension int
+}
+
+// Parses a frame header and returns whether it's a valid layer III header.
+func parseMP3Header(b []byte) (mp3Header, bool) {
+	var h mp3Header
+	if b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return h, false
+	}
+	h.version = int(b[1]>>3) & 3
+	layer := int(b[1]>>1) & 3
+	bitrateIndex := int(b[2] >> 4)
+	rate := int(b[2]>>2) & 3
+	if h.version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rate == 3 {
+		return h, false
+	}
+	h.crc = b[1]&1 == 0
+	switch h.version {
+	case mp3Version1:
+		h.bitrate = mp3Bitrates[0][bitrateIndex]
+		h.rateIndex = rate
+	case mp3Version2:
+		h.bitrate = mp3Bitrates[1][bitrateIndex]
+		h.rateIndex = 3 + rate
+	case mp3Version25:
+		h.bitrate = mp3Bitrates[1][bitrateIndex]
+		h.rateIndex = 6 + rate
+	}
+	h.padding = b[2]&2 != 0
+	h.mode = int(b[3] >> 6)
+	h.modeExtension = int(b[3]>>4) & 3
+	return h, true
+}
+
+// Returns whether frames with the headers can be part of the same stream.
+func (h mp3Header) compatible(other mp3Header) bool {
+	return h.version == other.version && h.rateIndex == other.rateIndex && h.channels() == other.channels()
+}
+
+// Returns whether the frame uses the lower sampling frequencies of MPEG-2 (or
+// MPEG-2.5).
+func (h mp3Header) lsf() bool {
+	return h.version != mp3Version1
+}
+
+func (h mp3Header) sampleRate() int {
+	return mp3SampleRates[h.rateIndex]
+}
+
+func (h mp3Header) channels() int {
+	if h.mode == mp3ModeSingleChannel {
+		return 1
+	}
+	return 2
+}
+
+func (h mp3Header) granules() int {
+	if h.lsf() {
+		return 1
+	}
+	return 2
+}
+
+// Returns the size of the frame in bytes, including the header.
+func (h mp3Header) frameSize() int {
+	size := 144000 * h.bitrate / h.sampleRate()
+	if h.lsf() {
+		size /= 2
+	}
+	if h.padding {
+		size++
+	}
+	return size
+}
+
+func (h mp3Header) sideInfoSize() int {
+	switch {
+	case h.lsf() && h.channels() == 1:
+		return 9
+	case h.lsf() || h.channels() == 1:
+		return 17
+	default:
+		return 32
+	}
+}
+
+/********** Side information **********/
+
+type mp3Granule struct {
+	part23Length     int
+	bigValues        int
+	globalGain       int
+	scalefacCompress int
+	blockType        int
+	mixed            bool
+	tableSelect      [3]int
+	subblockGain     [3]int
+	// The first frequency lines of regions 1 and 2 of the big values.
+	region1Start, region2Start int
+	preflag                    bool
+	scalefacScale              int
+	count1Table                int
+}
+
+// Returns whether the granule has short blocks (in all or some subbands).
+func (g *mp3Granule) short() bool {
+	return g.blockType == 2
+}
+
+type mp3SideInfo struct {
+	mainDataBegin int
+	// Whether scalefactors are shared between the granules, per channel and
+	// group of scalefactor bands (MPEG-1 only).
+	scfsi    [2][4]bool
+	granules [2][2]mp3Granule
+}
+
+func parseMP3SideInfo(h mp3Header, bits *mp3BitReader) *mp3SideInfo {
+	side := new(mp3SideInfo)
+	channels := h.channels()
+	if h.lsf() {
+		side.mainDataBegin = bits.read(8)
+		bits.read(channels) // Private bits.
+	} else {
+		side.mainDataBegin = bits.read(9)
+		bits.read(7 - 2*channels) // Private bits.
+		for ch := 0; ch < channels; ch++ {
+			for band := 0; band < 4; band++ {
+				side.scfsi[ch][band] = bits.read(1) == 1
+			}
+		}
+	}
+	long := mp3LongBands[h.rateIndex]
+	short := mp3ShortBands[h.rateIndex]
+	for gr := 0; gr < h.granules(); gr++ {
+		for ch := 0; ch < channels; ch++ {
+			g := &side.granules[gr][ch]
+			g.part23Length = bits.read(12)
+			g.bigValues = minInt(bits.read(9), mp3GranuleSize/2)
+			g.globalGain = bits.read(8)
+			if h.lsf() {
+				g.scalefacCompress = bits.read(9)
+			} else {
+				g.scalefacCompress = bits.read(4)
+			}
+			if bits.read(1) == 1 {
+				// Window switching.
+				g.blockType = bits.read(2)
+				g.mixed = bits.read(1) == 1
+				for i := 0; i < 2; i++ {
+					g.tableSelect[i] = bits.read(5)
+				}
+				for i := 0; i < 3; i++ {
+					g.subblockGain[i] = bits.read(3)
+				}
+				if g.short() && !g.mixed {
+					g.region1Start = 3 * short[3]
+				} else {
+					g.region1Start = long[8]
+				}
+				g.region2Start = mp3GranuleSize
+			} else {
+				for i := 0; i < 3; i++ {
+					g.tableSelect[i] = bits.read(5)
+				}
+				region0Count := bits.read(4)
+				region1Count := bits.read(3)
+				g.region1Start = long[minInt(region0Count+1, 22)]
+				g.region2Start = long[minInt(region0Count+region1Count+2, 22)]
+			}
+			if !h.lsf() {
+				g.preflag = bits.read(1) == 1
+			}
+			g.scalefacScale = bits.read(1)
+			g.count1Table = bits.read(1)
+		}
+	}
+	return side
+}
+
+/********** Scalefactors **********/
+
+type mp3Scalefactors struct {
+	long  [22]int
+	short [13][3]int
+	// The largest possible values of the scalefactors, which mean that
+	// intensity stereo isn't used in the band (MPEG-2 only).
+	longMax  [22]int
+	shortMax [13]int
+}
+
+// The lengths in bits of the scalefactors in MPEG-1, by scalefac_compress.
+var mp3ScalefactorLengths = [16][2]int{
+	{0, 0}, {0, 1}, {0, 2}, {0, 3}, {3, 0}, {1, 1}, {1, 2}, {1, 3},
+	{2, 1}, {2, 2}, {2, 3}, {3, 1}, {3, 2}, {3, 3}, {4, 2}, {4, 3},
+}
+
+// The first scalefactor band of each group of long blocks that may share
+// scalefactors between granules in MPEG-1.
+var mp3ScfsiBands = [5]int{0, 6, 11, 16, 21}
+
+// Reads the scalefactors of an MPEG-1 granule.
+func (d *MP3Decoder) readScalefactors(bits *mp3BitReader, g *mp3Granule, gr, ch int, scfsi [4]bool) {
+	sf := &d.scalefactors[gr][ch]
+	*sf = mp3Scalefactors{}
+	slen := mp3ScalefactorLengths[g.scalefacCompress]
+	if g.short() {
+		band := 0
+		if g.mixed {
+			for ; band < 8; band++ {
+				sf.long[band] = bits.read(slen[0])
+			}
+			band = 3
+		}
+		for ; band < 12; band++ {
+			n := slen[0]
+			if band >= 6 {
+				n = slen[1]
+			}
+			for w := 0; w < 3; w++ {
+				sf.short[band][w] = bits.read(n)
+			}
+		}
+		return
+	}
+	for group := 0; group < 4; group++ {
+		n := slen[0]
+		if group >= 2 {
+			n = slen[1]
+		}
+		for band := mp3ScfsiBands[group]; band < mp3ScfsiBands[group+1]; band++ {
+			if gr == 1 && scfsi[group] {
+				sf.long[band] = d.scalefactors[0][ch].long[band]
+			} else {
+				sf.long[band] = bits.read(n)
+			}
+		}
+	}
+}
+
+// The number of scalefactors in each of the four groups of an MPEG-2 granule,
+// by the way scalefac_compress is interpreted and by block type (long, short
+// and mixed).
+var mp3LSFScalefactorCounts = [6][3][4]int{
+	{{6, 5, 5, 5}, {9, 9, 9, 9}, {6, 9, 9, 9}},
+	{{6, 5, 7, 3}, {9, 9, 12, 6}, {6, 9, 12, 6}},
+	{{11, 10, 0, 0}, {18, 18, 0, 0}, {15, 18, 0, 0}},
+	{{7, 7, 7, 0}, {12, 12, 12, 0}, {6, 15, 12, 0}},
+	{{6, 6, 6, 3}, {12, 9, 9, 6}, {6, 12, 9, 6}},
+	{{8, 8, 5, 0}, {15, 12, 9, 0}, {6, 18, 9, 0}},
+}
+
+// Reads the scalefactors of an MPEG-2 granule.
+func (d *MP3Decoder) readLSFScalefactors(h mp3Header, bits *mp3BitReader, g *mp3Granule, ch int) {
+	sf := &d.scalefactors[0][ch]
+	*sf = mp3Scalefactors{}
+	var slen [4]int
+	var table int
+	c := g.scalefacCompress
+	if ch == 1 && h.modeExtension&1 != 0 {
+		// The right channel of intensity stereo.
+		c >>= 1
+		switch {
+		case c < 180:
+			slen = [4]int{c / 36, c % 36 / 6, c % 6, 0}
+			table = 3
+		case c < 244:
+			c -= 180
+			slen = [4]int{c >> 4, c >> 2 & 3, c & 3, 0}
+			table = 4
+		default:
+			c -= 244
+			slen = [4]int{c / 3, c % 3, 0, 0}
+			table = 5
+		}
+	} else {
+		switch {
+		case c < 400:
+			slen = [4]int{c >> 4 / 5, c >> 4 % 5, c >> 2 & 3, c & 3}
+			table = 0
+		case c < 500:
+			c -= 400
+			slen = [4]int{c >> 2 / 5, c >> 2 % 5, c & 3, 0}
+			table = 1
+		default:
+			c -= 500
+			slen = [4]int{c / 3, c % 3, 0, 0}
+			table = 2
+			g.preflag = true
+		}
+	}
+	blockIndex := 0
+	if g.short() && g.mixed {
+		blockIndex = 2
+	} else if g.short() {
+		blockIndex = 1
+	}
+	var values, max []int
+	for group, count := range mp3LSFScalefactorCounts[table][blockIndex] {
+		for i := 0; i < count; i++ {
+			values = append(values, bits.read(slen[group]))
+			max = append(max, 1<<uint(slen[group])-1)
+		}
+	}
+	i := 0
+	band := 0
+	if !g.short() {
+		for ; i < len(values) && band < 21; i, band = i+1, band+1 {
+			sf.long[band], sf.longMax[band] = values[i], max[i]
+		}
+		return
+	}
+	if g.mixed {
+		for ; band < 6; i, band = i+1, band+1 {
+			sf.long[band], sf.longMax[band] = values[i], max[i]
+		}
+		band = 3
+	}
+	for ; i+2 < len(values) && band < 12; i, band = i+3, band+1 {
+		for w := 0; w < 3; w++ {
+			sf.short[band][w] = values[i+w]
+		}
+		sf.shortMax[band] = max[i]
+	}
+}
+
+/********** Huffman decoding **********/
+
+// The number of linbits of each Huffman table.
+var mp3Linbits = [32]int{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 2, 3, 4, 6, 8, 10, 13, 4, 5, 6, 7, 8, 9, 11, 13,
+}
+
+// Decoding trees of the Huffman tables. Each node is a pair of entries for the
+// bits 0 and 1, which are either the index of the next node or, if negative,
+// -1 - the decoded value. Decoding starts at node 0.
+var mp3HuffmanTrees [34][]int16
+
+func init() {
+	for t, codes := range mp3HuffmanCodes {
+		if codes == nil {
+			continue
+		}
+		n := int(math.Sqrt(float64(len(codes))))
+		tree := []int16{0, 0}
+		for i, c := range codes {
+			value := int16(i/n<<4 | i%n)
+			if t >= 32 {
+				value = int16(i)
+			}
+			length := uint(c >> 24)
+			node := 0
+			for bit := length; bit > 0; bit-- {
+				j := 2*node + int(c>>(bit-1)&1)
+				if bit == 1 {
+					tree[j] = -1 - value
+					break
+				}
+				if tree[j] == 0 {
+					tree[j] = int16(len(tree) / 2)
+					tree = append(tree, 0, 0)
+				}
+				node = int(tree[j])
+			}
+		}
+		mp3HuffmanTrees[t] = tree
+	}
+	for t := 17; t < 32; t++ {
+		if t != 24 {
+			base := 16
+			if t > 24 {
+				base = 24
+			}
+			mp3HuffmanTrees[t] = mp3HuffmanTrees[base]
+		}
+	}
+}
+
+// Decodes a value with a Huffman table, or returns -1 for an invalid code.
+func decodeMP3Huffman(bits *mp3BitReader, table int) int {
+	tree := mp3HuffmanTrees[table]
+	node := 0
+	for {
+		next := tree[2*node+bits.read(1)]
+		if next < 0 {
+			return int(-1 - next)
+		}
+		if next == 0 {
+			return -1
+		}
+		node = int(next)
+	}
+}
+
+// Reads the quantized values of a granule up to the bit position end and
+// returns the number of values which may be nonzero.
+func readMP3Huffman(h mp3Header, bits *mp3BitReader, g *mp3Granule, end int, values *[mp3GranuleSize]int) int {
+	i := 0
+	for ; i < g.bigValues*2; i += 2 {
+		table := g.tableSelect[0]
+		if i >= g.region2Start {
+			table = g.tableSelect[2]
+		} else if i >= g.region1Start {
+			table = g.tableSelect[1]
+		}
+		if mp3HuffmanTrees[table] == nil {
+			continue
+		}
+		v := decodeMP3Huffman(bits, table)
+		if v < 0 {
+			return i
+		}
+		linbits := mp3Linbits[table]
+		values[i] = readMP3Value(bits, v>>4, linbits)
+		values[i+1] = readMP3Value(bits, v&15, linbits)
+	}
+	for i+4 <= mp3GranuleSize && bits.pos < end {
+		v := decodeMP3Huffman(bits, 32+g.count1Table)
+		if v < 0 {
+			break
+		}
+		for j := 0; j < 4; j++ {
+			values[i+j] = readMP3Value(bits, v>>uint(3-j)&1, 0)
+		}
+		if bits.pos > end {
+			// The last values were padding which overlaps the next granule.
+			for j := 0; j < 4; j++ {
+				values[i+j] = 0
+			}
+			break
+		}
+		i += 4
+	}
+	return i
+}
+
+// Reads the linbits and sign of a value.
+func readMP3Value(bits *mp3BitReader, v, linbits int) int {
+	if linbits > 0 && v == 15 {
+		v += bits.read(linbits)
+	}
+	if v != 0 && bits.read(1) == 1 {
+		return -v
+	}
+	return v
+}
+
+/********** Requantization and stereo **********/
+
+// The amounts added to the scalefactors of long blocks if preflag is set.
+var mp3Pretab = [22]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 3, 3, 3, 2, 0}
+
+// |x|^(4/3) for all possible quantized values.
+var mp3Pow43 [8207]float64
+
+func init() {
+	for i := range mp3Pow43 {
+		mp3Pow43[i] = math.Pow(float64(i), 4.0/3)
+	}
+}
+
+// Returns the first frequency line of the first short scalefactor band of the
+// granule (which is 0 unless the granule has mixed blocks).
+func mp3ShortStart(h mp3Header, g *mp3Granule) (line, band int) {
+	if !g.mixed {
+		return 0, 0
+	}
+	return 3 * mp3ShortBands[h.rateIndex][3], 3
+}
+
+// Converts the quantized values of a granule to frequency lines.
+func (d *MP3Decoder) requantize(h mp3Header, g *mp3Granule, sf *mp3Scalefactors, values *[mp3GranuleSize]int, xr *[mp3GranuleSize]float64) {
+	scale := 0.5 * float64(1+g.scalefacScale)
+	dequantize := func(i int, exponent float64) {
+		v := values[i]
+		if v == 0 {
+			return
+		}
+		x := mp3Pow43[minInt(abs(v), len(mp3Pow43)-1)] * math.Pow(2, exponent)
+		if v < 0 {
+			x = -x
+		}
+		xr[i] = x
+	}
+	gain := 0.25 * float64(g.globalGain-210)
+	long := mp3LongBands[h.rateIndex]
+	shortStart, shortBand := mp3ShortStart(h, g)
+	if !g.short() {
+		shortStart = mp3GranuleSize
+	}
+	for band := 0; band < 22 && long[band] < shortStart; band++ {
+		exponent := float64(sf.long[band])
+		if g.preflag {
+			exponent += float64(mp3Pretab[band])
+		}
+		for i := long[band]; i < long[band+1]; i++ {
+			dequantize(i, gain-scale*exponent)
+		}
+	}
+	if !g.short() {
+		return
+	}
+	short := mp3ShortBands[h.rateIndex]
+	for band := shortBand; band < 13; band++ {
+		width := short[band+1] - short[band]
+		for w := 0; w < 3; w++ {
+			exponent := gain - 2*float64(g.subblockGain[w]) - scale*float64(sf.short[band][w])
+			start := 3*short[band] + w*width
+			for i := start; i < start+width; i++ {
+				dequantize(i, exponent)
+			}
+		}
+	}
+}
+
+// Applies mid/side and intensity stereo to the frequency lines of a granule.
+// The right channel's granule and scalefactors determine which bands use
+// intensity stereo.
+func (d *MP3Decoder) stereo(h mp3Header, g *mp3Granule, sf *mp3Scalefactors, xr *[2][mp3GranuleSize]float64, nonzero [2]int) {
+	// The intensity stereo factors of the left and right channels per line,
+	// if intensity stereo is used for the line.
+	var intensity [mp3GranuleSize]bool
+	var kl, kr [mp3GranuleSize]float64
+	if h.modeExtension&1 != 0 {
+		position := func(start, end, pos, max int) {
+			if pos == max {
+				return
+			}
+			l, r := d.intensityFactors(h, g, pos)
+			for i := start; i < end; i++ {
+				intensity[i], kl[i], kr[i] = true, l, r
+			}
+		}
+		long := mp3LongBands[h.rateIndex]
+		// The last band has no scalefactor of its own and uses the previous one.
+		longPos := func(band int) (int, int) {
+			if band == 21 {
+				band = 20
+			}
+			return sf.long[band], d.maxIntensity(h, sf.longMax[band])
+		}
+		shortStart, shortBand := mp3ShortStart(h, g)
+		if !g.short() {
+			// Intensity stereo is used above the last nonzero line of the right
+			// channel.
+			last := lastNonzero(&xr[1], 0, nonzero[1])
+			for band := 0; band < 22; band++ {
+				if long[band] > last {
+					pos, max := longPos(band)
+					position(long[band], long[band+1], pos, max)
+				}
+			}
+		} else {
+			short := mp3ShortBands[h.rateIndex]
+			allZero := true
+			for w := 0; w < 3; w++ {
+				// Find the last short band with a nonzero line in the window.
+				lastBand := shortBand - 1
+				for band := shortBand; band < 13; band++ {
+					width := short[band+1] - short[band]
+					start := 3*short[band] + w*width
+					if lastNonzero(&xr[1], start, start+width) >= start {
+						lastBand = band
+					}
+				}
+				if lastBand >= shortBand {
+					allZero = false
+				}
+				for band := lastBand + 1; band < 13; band++ {
+					b := minInt(band, 11)
+					width := short[band+1] - short[band]
+					start := 3*short[band] + w*width
+					position(start, start+width, sf.short[b][w], d.maxIntensity(h, sf.shortMax[b]))
+				}
+			}
+			if g.mixed && allZero {
+				last := lastNonzero(&xr[1], 0, shortStart)
+				for band := 0; long[band] < shortStart; band++ {
+					if long[band] > last {
+						pos, max := longPos(band)
+						position(long[band], long[band+1], pos, max)
+					}
+				}
+			}
+		}
+	}
+	ms := h.modeExtension&2 != 0
+	for i := 0; i < mp3GranuleSize; i++ {
+		l, r := xr[0][i], xr[1][i]
+		if intensity[i] {
+			xr[0][i], xr[1][i] = l*kl[i], l*kr[i]
+		} else if ms {
+			xr[0][i], xr[1][i] = (l+r)/math.Sqrt2, (l-r)/math.Sqrt2
+		}
+	}
+}
+
+// Returns the value of an intensity stereo position which means that
+// intensity stereo isn't used.
+func (d *MP3Decoder) maxIntensity(h mp3Header, max int) int {
+	if h.lsf() {
+		return max
+	}
+	return 7
+}
+
+// Returns the factors of the left and right channel for an intensity stereo
+// position.
+func (d *MP3Decoder) intensityFactors(h mp3Header, g *mp3Granule, pos int) (float64, float64) {
+	if !h.lsf() {
+		if pos == 6 {
+			return 1, 0
+		}
+		ratio := math.Tan(float64(pos) * math.Pi / 12)
+		return ratio / (1 + ratio), 1 / (1 + ratio)
+	}
+	i0 := math.Pow(2, -0.25)
+	if g.scalefacCompress&1 == 1 {
+		i0 = math.Sqrt(0.5)
+	}
+	switch {
+	case pos == 0:
+		return 1, 1
+	case pos%2 == 1:
+		return math.Pow(i0, float64(pos+1)/2), 1
+	default:
+		return 1, math.Pow(i0, float64(pos)/2)
+	}
+}
+
+// Returns the index of the last nonzero line in [start, end), or start - 1.
+func lastNonzero(xr *[mp3GranuleSize]float64, start, end int) int {
+	for i := end - 1; i >= start; i-- {
+		if xr[i] != 0 {
+			return i
+		}
+	}
+	return start - 1
+}
+
+/********** Synthesis **********/
+
+// Reorders the lines of short blocks, which are grouped by window, so that
+// they're grouped by frequency.
+func mp3Reorder(h mp3Header, g *mp3Granule, xr *[mp3GranuleSize]float64) {
+	if !g.short() {
+		return
+	}
+	short := mp3ShortBands[h.rateIndex]
+	_, shortBand := mp3ShortStart(h, g)
+	var reordered [mp3GranuleSize]float64
+	for band := shortBand; band < 13; band++ {
+		start := 3 * short[band]
+		width := short[band+1] - short[band]
+		for w := 0; w < 3; w++ {
+			for j := 0; j < width; j++ {
+				reordered[start+3*j+w] = xr[start+w*width+j]
+			}
+		}
+	}
+	start := 3 * short[shortBand]
+	copy(xr[start:], reordered[start:])
+}
+
+// Coefficients of the alias reduction butterflies.
+var mp3AliasCs, mp3AliasCa [8]float64
+
+func init() {
+	c := [8]float64{-0.6, -0.535, -0.33, -0.185, -0.095, -0.041, -0.0142, -0.0037}
+	for i, v := range c {
+		mp3AliasCs[i] = 1 / math.Sqrt(1+v*v)
+		mp3AliasCa[i] = v / math.Sqrt(1+v*v)
+	}
+}
+
+// Reduces the aliasing between the subbands of long blocks.
+func mp3Antialias(g *mp3Granule, xr *[mp3GranuleSize]float64) {
+	subbands := 32
+	if g.short() {
+		if !g.mixed {
+			return
+		}
+		subbands = 2
+	}
+	for sb := 1; sb < subbands; sb++ {
+		for i := 0; i < 8; i++ {
+			lo, hi := 18*sb-1-i, 18*sb+i
+			a, b := xr[lo], xr[hi]
+			xr[lo] = a*mp3AliasCs[i] - b*mp3AliasCa[i]
+			xr[hi] = b*mp3AliasCs[i] + a*mp3AliasCa[i]
+		}
+	}
+}
+
+// The IMDCT coefficients of long and short blocks, and the windows of the
+// four block types.
+var (
+	mp3IMDCTLong  [36][18]float64
+	mp3IMDCTShort [12][6]float64
+	mp3Windows    [4][36]float64
+)
+
+func init() {
+	for i := 0; i < 36; i++ {
+		for k := 0; k < 18; k++ {
+			mp3IMDCTLong[i][k] = math.Cos(math.Pi / 72 * float64((2*i+1+18)*(2*k+1)))
+		}
+	}
+	for i := 0; i < 12; i++ {
+		for k := 0; k < 6; k++ {
+			mp3IMDCTShort[i][k] = math.Cos(math.Pi / 24 * float64((2*i+1+6)*(2*k+1)))
+		}
+	}
+	for i := 0; i < 36; i++ {
+		mp3Windows[0][i] = math.Sin(math.Pi / 36 * (float64(i) + 0.5))
+	}
+	for i := 0; i < 18; i++ {
+		mp3Windows[1][i] = mp3Windows[0][i]
+		mp3Windows[3][i+18] = mp3Windows[0][i+18]
+	}
+	for i := 0; i < 6; i++ {
+		mp3Windows[1][18+i] = 1
+		mp3Windows[1][24+i] = math.Sin(math.Pi / 12 * (float64(i) + 0.5 + 6))
+		mp3Windows[3][6+i] = math.Sin(math.Pi / 12 * (float64(i) + 0.5))
+		mp3Windows[3][12+i] = 1
+	}
+	for i := 0; i < 12; i++ {
+		mp3Windows[2][i] = math.Sin(math.Pi / 12 * (float64(i) + 0.5))
+	}
+}
+
+// Transforms the frequency lines of each subband to time samples with the
+// IMDCT and overlaps them with the previous granule.
+func (d *MP3Decoder) hybrid(g *mp3Granule, ch int, xr *[mp3GranuleSize]float64) {
+	for sb := 0; sb < 32; sb++ {
+		in := xr[18*sb : 18*sb+18]
+		var out [36]float64
+		blockType := g.blockType
+		if g.mixed && sb < 2 {
+			blockType = 0
+		}
+		if blockType == 2 {
+			for w := 0; w < 3; w++ {
+				for i := 0; i < 12; i++ {
+					var sum float64
+					for k := 0; k < 6; k++ {
+						sum += in[3*k+w] * mp3IMDCTShort[i][k]
+					}
+					out[6+6*w+i] += sum * mp3Windows[2][i]
+				}
+			}
+		} else {
+			window := &mp3Windows[blockType]
+			for i := 0; i < 36; i++ {
+				var sum float64
+				for k := 0; k < 18; k++ {
+					sum += in[k] * mp3IMDCTLong[i][k]
+				}
+				out[i] = sum * window[i]
+			}
+		}
+		overlap := d.overlap[ch][18*sb : 18*sb+18]
+		for i := 0; i < 18; i++ {
+			in[i] = out[i] + overlap[i]
+			overlap[i] = out[18+i]
+			// Compensate for the frequency inversion of odd subbands.
+			if sb%2 == 1 && i%2 == 1 {
+				in[i] = -in[i]
+			}
+		}
+	}
+}
+
+// The matrixing coefficients and the window of the synthesis filterbank.
+var (
+	mp3SynthesisMatrix [64][32]float64
+	mp3SynthesisWindow [512]float64
+)
+
+func init() {
+	for i := 0; i < 64; i++ {
+		for k := 0; k < 32; k++ {
+			mp3SynthesisMatrix[i][k] = math.Cos(float64((16+i)*(2*k+1)) * math.Pi / 64)
+		}
+	}
+	for i, v := range mp3SynthesisWindowHalf {
+		mp3SynthesisWindow[i] = float64(v) / (1 << 16)
+		if i > 0 && i < 256 {
+			// The window is antisymmetric, except for every 64th value.
+			sign := -1.0
+			if i%64 == 0 {
+				sign = 1
+			}
+			mp3SynthesisWindow[512-i] = sign * mp3SynthesisWindow[i]
+		}
+	}
+}
+
+// Converts the subband samples of a granule to PCM samples, which are written
+// to every step-th element of out.
+func (d *MP3Decoder) synthesize(ch int, xr *[mp3GranuleSize]float64, out []int16, step int) {
+	v := &d.v[ch]
+	for t := 0; t < 18; t++ {
+		copy(v[64:], v[:1024-64])
+		for i := 0; i < 64; i++ {
+			var sum float64
+			for k := 0; k < 32; k++ {
+				sum += mp3SynthesisMatrix[i][k] * xr[18*k+t]
+			}
+			v[i] = sum
+		}
+		for j := 0; j < 32; j++ {
+			var sum float64
+			for i := 0; i < 8; i++ {
+				sum += v[128*i+j] * mp3SynthesisWindow[64*i+j]
+				sum += v[128*i+96+j] * mp3SynthesisWindow[64*i+32+j]
+			}
+			s := math.Max(-32768, math.Min(32767, math.Floor(sum*32768+0.5)))
+			out[(32*t+j)*step] = int16(s)
+		}
+	}
+}
+
+/********** Tables and helpers **********/
+
+// The first frequency line of each long scalefactor band, by sample rate
+// index.
+var mp3LongBands = [9][23]int{
+	{0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 52, 62, 74, 90, 110, 134, 162, 196, 238, 288, 342, 418, 576},
+	{0, 4, 8, 12, 16, 20, 24, 30, 36, 42, 50, 60, 72, 88, 106, 128, 156, 190, 230, 276, 330, 384, 576},
+	{0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 54, 66, 82, 102, 126, 156, 194, 240, 296, 364, 448, 550, 576},
+	{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+	{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 114, 136, 162, 194, 232, 278, 332, 394, 464, 540, 576},
+	{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+	{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+	{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+	{0, 12, 24, 36, 48, 60, 72, 88, 108, 132, 160, 192, 232, 280, 336, 400, 476, 566, 568, 570, 572, 574, 576},
+}
+
+// The first frequency line of each short scalefactor band (in a single
+// window), by sample rate index.
+var mp3ShortBands = [9][14]int{
+	{0, 4, 8, 12, 16, 22, 30, 40, 52, 66, 84, 106, 136, 192},
+	{0, 4, 8, 12, 16, 22, 28, 38, 50, 64, 80, 100, 126, 192},
+	{0, 4, 8, 12, 16, 22, 30, 42, 58, 78, 104, 138, 180, 192},
+	{0, 4, 8, 12, 18, 24, 32, 42, 56, 74, 100, 132, 174, 192},
+	{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 136, 180, 192},
+	{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	{0, 8, 16, 24, 36, 52, 72, 96, 124, 160, 162, 164, 166, 192},
+}
+
+// Reads bits from a byte slice, most significant bit first. Reading past the
+// end returns zeros.
+type mp3BitReader struct {
+	data []byte
+	pos  int
+}
+
+func (b *mp3BitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := 0
+		if byteIndex := b.pos >> 3; byteIndex < len(b.data) {
+			bit = int(b.data[byteIndex]>>uint(7-b.pos&7)) & 1
+		}
+		v = v<<1 | bit
+		b.pos++
+	}
+	return v
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,151 @@
+package avs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+// A layer III frame, repeated to make a stream, and the audio it decodes to.
+type mp3Fixture struct {
+	name string
+	// The start of the frame, which is padded with zeros to its size.
+	frame  string
+	size   int
+	format AudioFormat
+	// The number of samples per channel in each frame.
+	frameSamples int
+	// Decoded samples (interleaved, if there are two channels) from an
+	// independent MP3 decoder, starting at the frame where the tone has
+	// reached a steady state. Since every frame is the same, so is the start
+	// of the audio of every frame after that.
+	steadyFrame int
+	samples     []int16
+}
+
+var mp3Fixtures = []mp3Fixture{
+	// MPEG-1 (32 kbps, 32 kHz, mono) with two granules that each have a single
+	// non-zero frequency line: line 40 is 1 (Huffman table 1) at a global gain
+	// of 202. It decodes to a steady tone of about 1014 Hz.
+	{
+		name:         "MPEG-1 mono",
+		frame:        "fffb18c00000005c2b94008420000b857280108400fffff5ffffe8",
+		size:         144,
+		format:       AudioFormat{SampleRate: 32000, Channels: 1, BitsPerSample: 16},
+		frameSamples: 1152,
+		steadyFrame:  1,
+		samples: []int16{
+			4319, 2660, 880, -938, -2711, -4354, -5789, -6950,
+			-7782, -8247, -8323, -8007, -7315, -6280, -4952, -3393,
+		},
+	},
+	// MPEG-2 LSF (32 kbps, 24 kHz, mono), as AVS uses for speech, with a single
+	// granule in which lines 8 and 40 are 1. The scalefactors are coded with
+	// scalefac_compress 80, which gives the first six bands one bit each, and
+	// band 1 (which has line 8) has a scalefactor of 1.
+	{
+		name:         "MPEG-2 LSF mono",
+		frame:        "fff344c00000f85728a008420043d7fff4",
+		size:         96,
+		format:       AudioFormat{SampleRate: 24000, Channels: 1, BitsPerSample: 16},
+		frameSamples: 576,
+		steadyFrame:  2,
+		samples: []int16{
+			7211, 5769, 4199, 2583, 1008, -444, -1697, -2683,
+			-3348, -3655, -3581, -3125, -2301, -1144, 295, 1956,
+		},
+	},
+	// MPEG-1 (48 kbps, 32 kHz) joint stereo with M/S stereo, where the mid
+	// channel has line 40 set to 1 and the side channel has line 20 set to -1.
+	{
+		name:         "MPEG-1 joint stereo",
+		frame:        "fffb3860000000170ae50021080001a0bca0042100005c2b94008420000682f280108400fffff5ffbfffff5ffb",
+		size:         216,
+		format:       AudioFormat{SampleRate: 32000, Channels: 2, BitsPerSample: 16},
+		frameSamples: 1152,
+		steadyFrame:  1,
+		samples: []int16{
+			-202, 6310, -1919, 5682, -3679, 4924, -5417, 4089,
+			-7067, 3233, -8566, 2408, -9855, 1667, -10884, 1054,
+		},
+	},
+}
+
+// Returns a stream of n copies of the fixture's frame.
+func (f mp3Fixture) stream(n int) []byte {
+	frame, err := hex.DecodeString(f.frame)
+	if err != nil {
+		panic(err)
+	}
+	frame = append(frame, make([]byte, f.size-len(frame))...)
+	return bytes.Repeat(frame, n)
+}
+
+func decodeMP3(t *testing.T, data []byte, oneByte bool) ([]int16, AudioFormat) {
+	var r io.Reader = bytes.NewReader(data)
+	if oneByte {
+		r = iotest.OneByteReader(r)
+	}
+	d, err := NewMP3Decoder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm, err := ioutil.ReadAll(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+	}
+	return samples, d.Format()
+}
+
+func TestMP3DecoderFixtures(t *testing.T) {
+	for _, f := range mp3Fixtures {
+		samples, format := decodeMP3(t, f.stream(4), false)
+		if format != f.format {
+			t.Errorf("%s: got format %s, want %s", f.name, format, f.format)
+			continue
+		}
+		frameSize := f.frameSamples * f.format.Channels
+		if len(samples) != 4*frameSize {
+			t.Errorf("%s: got %d samples, want %d", f.name, len(samples), 4*frameSize)
+			continue
+		}
+		for frame := f.steadyFrame; frame < 4; frame++ {
+			for i, w := range f.samples {
+				got := samples[frame*frameSize+i]
+				// Rounding may differ by one between decoders.
+				if d := int(got) - int(w); d < -1 || d > 1 {
+					t.Errorf("%s: frame %d, sample %d: got %d, want %d", f.name, frame, i, got, w)
+				}
+			}
+		}
+	}
+}
+
+func TestMP3DecoderStream(t *testing.T) {
+	// An ID3v2 tag (with an empty body) before the first frame is skipped.
+	data := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), mp3Fixtures[0].stream(3)...)
+	want, _ := decodeMP3(t, data, false)
+	got, _ := decodeMP3(t, data, true)
+	if len(got) != 3*1152 || len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d: got %d when reading one byte at a time, want %d", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMP3DecoderNoFrames(t *testing.T) {
+	if _, err := NewMP3Decoder(bytes.NewReader([]byte("not an MP3 file"))); err != ErrNoMP3Frames {
+		t.Errorf("got error %v, want %v", err, ErrNoMP3Frames)
+	}
+}
@@ -0,0 +1,200 @@
+package avs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultNotificationTone is a short chime which is played when the audio
+// asset of a SetIndicator directive can't be fetched. Its format is
+// DefaultNotificationToneFormat.
+var DefaultNotificationTone = newBeep(660, 100*time.Millisecond, 2)
+
+// DefaultNotificationToneFormat is the format of DefaultNotificationTone.
+const DefaultNotificationToneFormat = "audio/wav"
+
+// VisualIndicator turns the device's notification indicator (e.g., a
+// pulsing yellow light ring) on and off.
+type VisualIndicator interface {
+	SetVisualIndicator(on bool) error
+}
+
+// Notifications applies the Notifications directives, which tell the device
+// that the user has a pending notification (e.g., a delivery update). It
+// keeps the indicator state, shows the visual indicator and plays the audio
+// indicator through an AudioSink.
+type Notifications struct {
+	Sink AudioSink
+	// Indicator is optional. If it's set, it's turned on while a persisted
+	// visual indicator is set.
+	Indicator VisualIndicator
+	// Focus is optional. If it's set, the audio indicator uses the
+	// notifications channel. If the channel isn't in the foreground (e.g.,
+	// while an alert is sounding), the audio indicator is queued and played
+	// once the channel returns to the foreground. Only the latest queued audio
+	// indicator is played.
+	Focus *FocusManager
+	// DoNotDisturb is optional. If it's set, audio indicators aren't played
+	// while do not disturb is enabled.
+	DoNotDisturb *DoNotDisturb
+	// HTTPClient is used to fetch audio assets. If nil, http.DefaultClient is
+	// used.
+	HTTPClient *http.Client
+	// The audio which is played when an asset can't be fetched, in the format
+	// specified by ToneFormat.
+	Tone       []byte
+	ToneFormat string
+	// ErrorHandler is optional. It's called with the errors of queued audio
+	// indicators, which are played in the background.
+	ErrorHandler func(err error)
+
+	// Held while the audio indicator is playing so that it never overlaps.
+	playing sync.Mutex
+
+	mu        sync.Mutex
+	enabled   bool
+	persisted bool
+	state     FocusState
+	// The audio indicator which is waiting for the foreground, if any.
+	pending *Asset
+}
+
+// NewNotifications returns a Notifications which plays audio indicators
+// through the provided sink.
+func NewNotifications(sink AudioSink) *Notifications {
+	return &Notifications{
+		Sink:       sink,
+		Tone:       DefaultNotificationTone,
+		ToneFormat: DefaultNotificationToneFormat,
+	}
+}
+
+// SetIndicator applies the SetIndicator directive. If the directive asks for
+// the audio indicator, SetIndicator blocks until it has been played, unless it
+// has to be queued (see Focus). The visual indicator is shown even while do
+// not disturb is enabled.
+func (n *Notifications) SetIndicator(d *SetIndicator) error {
+	n.mu.Lock()
+	n.enabled = true
+	// A persisted indicator stays until it's cleared.
+	n.persisted = n.persisted || d.Payload.PersistVisualIndicator
+	persisted := n.persisted
+	n.mu.Unlock()
+	if persisted && n.Indicator != nil {
+		if err := n.Indicator.SetVisualIndicator(true); err != nil {
+			return err
+		}
+	}
+	if !d.Payload.PlayAudioIndicator || (n.DoNotDisturb != nil && n.DoNotDisturb.Enabled()) {
+		return nil
+	}
+	return n.play(d.Payload.Asset)
+}
+
+// ClearIndicator applies the ClearIndicator directive. A queued audio
+// indicator is dropped.
+func (n *Notifications) ClearIndicator(d *ClearIndicator) error {
+	n.mu.Lock()
+	wasPersisted := n.persisted
+	wasPending := n.pending != nil
+	n.enabled = false
+	n.persisted = false
+	n.pending = nil
+	n.mu.Unlock()
+	if wasPending {
+		n.Focus.Release(FocusChannelNotifications, n)
+	}
+	if wasPersisted && n.Indicator != nil {
+		return n.Indicator.SetVisualIndicator(false)
+	}
+	return nil
+}
+
+// IndicatorState returns the current IndicatorState context.
+func (n *Notifications) IndicatorState() *IndicatorState {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return NewIndicatorState(n.enabled, n.persisted)
+}
+
+// Context implements the ContextProvider interface.
+func (n *Notifications) Context() TypedMessage {
+	return n.IndicatorState()
+}
+
+// FocusChanged implements the FocusObserver interface. The audio indicator is
+// stopped if it loses the foreground, and a queued one is played when the
+// foreground returns.
+func (n *Notifications) FocusChanged(state FocusState) error {
+	n.mu.Lock()
+	wasForeground := n.state == FocusStateForeground
+	n.state = state
+	var pending *Asset
+	if state == FocusStateForeground {
+		pending, n.pending = n.pending, nil
+	}
+	n.mu.Unlock()
+	if pending != nil {
+		// The focus manager is still notifying observers, so the audio can't
+		// be played from here.
+		go n.playQueued(*pending)
+		return nil
+	}
+	if wasForeground && state != FocusStateForeground {
+		return n.Sink.Stop()
+	}
+	return nil
+}
+
+// Plays the audio indicator if the notifications channel is in the
+// foreground, or queues it until it is.
+func (n *Notifications) play(asset Asset) error {
+	n.playing.Lock()
+	defer n.playing.Unlock()
+	if n.Focus != nil {
+		n.Focus.Acquire(FocusChannelNotifications, n)
+		n.mu.Lock()
+		foreground := n.state == FocusStateForeground
+		if !foreground {
+			// The channel is kept so that FocusChanged plays the audio once
+			// it's in the foreground.
+			n.pending = &asset
+		}
+		n.mu.Unlock()
+		if !foreground {
+			return nil
+		}
+		defer n.Focus.Release(FocusChannelNotifications, n)
+	}
+	return n.playAudio(asset)
+}
+
+// Plays an audio indicator that was queued until the notifications channel
+// was in the foreground. It's queued again if the channel has lost the
+// foreground in the meantime.
+func (n *Notifications) playQueued(asset Asset) {
+	if err := n.play(asset); err != nil && n.ErrorHandler != nil {
+		n.ErrorHandler(err)
+	}
+}
+
+// Plays the audio asset, or the fallback tone if the asset can't be fetched.
+func (n *Notifications) playAudio(asset Asset) error {
+	format, audio, err := n.open(asset)
+	if err != nil {
+		format, audio = n.ToneFormat, ioutil.NopCloser(bytes.NewReader(n.Tone))
+	}
+	defer audio.Close()
+	return n.Sink.Play(format, audio)
+}
+
+// Opens the audio of an asset.
+func (n *Notifications) open(asset Asset) (format string, audio io.ReadCloser, err error) {
+	resolver := &StreamResolver{Client: n.HTTPClient}
+	format, audio, _, err = resolver.Open(asset.URL, 0)
+	return format, audio, err
+}
@@ -0,0 +1,123 @@
+package avs
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// An AudioSink which sends the format of everything it plays to a channel.
+type recordingSink chan string
+
+func (s recordingSink) Play(format string, audio io.Reader) error {
+	ioutil.ReadAll(audio)
+	s <- format
+	return nil
+}
+
+func (s recordingSink) Stop() error {
+	return nil
+}
+
+// An activity on the alerts channel.
+type testActivity struct{}
+
+func (*testActivity) FocusChanged(state FocusState) error {
+	return nil
+}
+
+func newTestNotifications() (*Notifications, recordingSink) {
+	sink := make(recordingSink, 10)
+	n := NewNotifications(sink)
+	n.Focus = NewFocusManager()
+	n.ToneFormat = "test/tone"
+	return n, sink
+}
+
+// Returns a SetIndicator directive which plays the audio indicator. Its asset
+// can't be fetched, so the tone is played.
+func audioIndicator() *SetIndicator {
+	d := new(SetIndicator)
+	d.Payload.PlayAudioIndicator = true
+	d.Payload.Asset.URL = "file:///chime.mp3"
+	return d
+}
+
+func TestNotificationsPlay(t *testing.T) {
+	n, sink := newTestNotifications()
+	if err := n.SetIndicator(audioIndicator()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case format := <-sink:
+		if format != "test/tone" {
+			t.Errorf("got format %q, want the tone", format)
+		}
+	default:
+		t.Fatal("the audio indicator wasn't played")
+	}
+	if state := n.Focus.State(FocusChannelNotifications); state != FocusStateNone {
+		t.Errorf("notifications channel is %s after playing, want it released", state)
+	}
+}
+
+func TestNotificationsQueued(t *testing.T) {
+	n, sink := newTestNotifications()
+	alert := new(testActivity)
+	n.Focus.Acquire(FocusChannelAlerts, alert)
+	// The audio indicator waits for the alert to stop.
+	if err := n.SetIndicator(audioIndicator()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sink:
+		t.Fatal("the audio indicator was played during an alert")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if state := n.Focus.State(FocusChannelNotifications); state != FocusStateBackground {
+		t.Errorf("notifications channel is %s while queued, want %s", state, FocusStateBackground)
+	}
+	// A second indicator replaces the first.
+	if err := n.SetIndicator(audioIndicator()); err != nil {
+		t.Fatal(err)
+	}
+	n.Focus.Release(FocusChannelAlerts, alert)
+	select {
+	case <-sink:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queued audio indicator wasn't played")
+	}
+	select {
+	case <-sink:
+		t.Fatal("the queued audio indicator was played twice")
+	case <-time.After(10 * time.Millisecond):
+	}
+	for i := 0; n.Focus.State(FocusChannelNotifications) != FocusStateNone; i++ {
+		if i == 500 {
+			t.Fatal("notifications channel wasn't released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNotificationsQueuedCleared(t *testing.T) {
+	n, sink := newTestNotifications()
+	alert := new(testActivity)
+	n.Focus.Acquire(FocusChannelAlerts, alert)
+	if err := n.SetIndicator(audioIndicator()); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.ClearIndicator(new(ClearIndicator)); err != nil {
+		t.Fatal(err)
+	}
+	if state := n.Focus.State(FocusChannelNotifications); state != FocusStateNone {
+		t.Errorf("notifications channel is %s after clearing, want it released", state)
+	}
+	n.Focus.Release(FocusChannelAlerts, alert)
+	select {
+	case <-sink:
+		t.Fatal("a cleared audio indicator was played")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,127 @@
+package avs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// OpusFrameSize is the size in bytes of every Opus packet that AVS accepts
+// (32 kbps CBR with 20 ms frames).
+const OpusFrameSize = 80
+
+// OpusAudio returns a reader of the raw Opus packets in the audio, which is
+// expected to be in RecognizeFormatOpus. If the audio is an Ogg file, the
+// packets are extracted from it; otherwise, the audio is assumed to already
+// be a sequence of packets.
+func OpusAudio(audio io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(audio)
+	if magic, _ := br.Peek(4); !bytes.Equal(magic, []byte("OggS")) {
+		return br, nil
+	}
+	return &oggOpusReader{r: br}, nil
+}
+
+// Extracts the packets of an Opus stream from an Ogg container.
+type oggOpusReader struct {
+	r io.Reader
+	// The number of packets read so far, including the two header packets.
+	packets int
+	// Segments of the current page that have yet to be read.
+	segments []byte
+	// A packet that may continue onto the next page.
+	packet []byte
+	// Packet data that has yet to be returned by Read.
+	out []byte
+}
+
+func (o *oggOpusReader) Read(p []byte) (int, error) {
+	for len(o.out) == 0 {
+		packet, err := o.nextPacket()
+		if err != nil {
+			return 0, err
+		}
+		o.packets++
+		switch o.packets {
+		case 1:
+			if err := checkOpusHead(packet); err != nil {
+				return 0, err
+			}
+		case 2:
+			// Ignore the OpusTags packet.
+		default:
+			if len(packet) != OpusFrameSize {
+				return 0, fmt.Errorf("opus packet is %d bytes (expected %d for 32 kbps CBR with 20 ms frames)", len(packet), OpusFrameSize)
+			}
+			o.out = packet
+		}
+	}
+	n := copy(p, o.out)
+	o.out = o.out[n:]
+	return n, nil
+}
+
+// Returns the next complete packet in the Ogg stream.
+func (o *oggOpusReader) nextPacket() ([]byte, error) {
+	for {
+		if len(o.segments) == 0 {
+			if err := o.readPageHeader(); err != nil {
+				if err == io.EOF && len(o.packet) > 0 {
+					return nil, io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			continue
+		}
+		size := int(o.segments[0])
+		o.segments = o.segments[1:]
+		start := len(o.packet)
+		o.packet = append(o.packet, make([]byte, size)...)
+		if _, err := io.ReadFull(o.r, o.packet[start:]); err != nil {
+			return nil, err
+		}
+		// A segment shorter than 255 bytes ends the packet.
+		if size < 255 {
+			packet := o.packet
+			o.packet = nil
+			return packet, nil
+		}
+	}
+}
+
+// Reads the header of the next Ogg page, including its segment table.
+func (o *oggOpusReader) readPageHeader() error {
+	var header struct {
+		Pattern         [4]byte
+		Version         uint8
+		Type            uint8
+		GranulePosition uint64
+		Serial          uint32
+		Sequence        uint32
+		Checksum        uint32
+		Segments        uint8
+	}
+	if err := binary.Read(o.r, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if string(header.Pattern[:]) != "OggS" || header.Version != 0 {
+		return errors.New("invalid Ogg page")
+	}
+	o.segments = make([]byte, header.Segments)
+	_, err := io.ReadFull(o.r, o.segments)
+	return err
+}
+
+// Checks that the OpusHead packet describes a stream that AVS accepts.
+func checkOpusHead(packet []byte) error {
+	if len(packet) < 19 || !bytes.Equal(packet[:8], []byte("OpusHead")) {
+		return errors.New("missing OpusHead packet")
+	}
+	if channels := packet[9]; channels != 1 {
+		return fmt.Errorf("opus stream has %d channels (expected 1)", channels)
+	}
+	return nil
+}
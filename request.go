@@ -11,6 +11,13 @@ type Request struct {
 	Audio       io.Reader      `json:"-"`
 	Context     []TypedMessage `json:"context"`
 	Event       TypedMessage   `json:"event"`
+	// The encoding of Audio. If it's empty, the format of the Recognize event
+	// is used. Otherwise, the Recognize event is sent with this format (Event
+	// itself isn't changed).
+	Format RecognizeFormat `json:"-"`
+	// OmitContext prevents Client.Do from adding the context of its context
+	// providers to the request. Context added with AddContext is still sent.
+	OmitContext bool `json:"-"`
 }
 
 // NewRequest returns a new Request given an access token.
@@ -23,7 +30,30 @@ func NewRequest(accessToken string) *Request {
 	}
 }
 
-// AddContext adds a context Message to the Request.
+// AddContext adds a context Message to the Request. It overrides any context
+// with the same namespace and name that Client.Do would add automatically.
 func (r *Request) AddContext(m TypedMessage) {
 	r.Context = append(r.Context, m)
 }
+
+// Returns the format of the audio, and a request whose Recognize event (if
+// any) declares the same format. The request is a copy if the event had to be
+// changed, so that the caller's event is left alone.
+func (r *Request) withAudioFormat() (*Request, RecognizeFormat) {
+	recognize, _ := r.Event.(*Recognize)
+	format := r.Format
+	if format == "" && recognize != nil {
+		format = RecognizeFormat(recognize.Payload.Format)
+	}
+	if format == "" {
+		format = RecognizeFormatL16
+	}
+	if recognize == nil || recognize.Payload.Format == string(format) {
+		return r, format
+	}
+	event := *recognize
+	event.Payload.Format = string(format)
+	request := *r
+	request.Event = &event
+	return &request, format
+}
@@ -0,0 +1,280 @@
+package avs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// SupportedLocales are the locales that AVS supports.
+var SupportedLocales = []SettingLocale{
+	SettingLocaleArSA,
+	SettingLocaleDeDE,
+	SettingLocaleEnAU,
+	SettingLocaleEnCA,
+	SettingLocaleEnGB,
+	SettingLocaleEnIN,
+	SettingLocaleEnUS,
+	SettingLocaleEsES,
+	SettingLocaleEsMX,
+	SettingLocaleEsUS,
+	SettingLocaleFrCA,
+	SettingLocaleFrFR,
+	SettingLocaleHiIN,
+	SettingLocaleItIT,
+	SettingLocaleJaJP,
+	SettingLocalePtBR,
+}
+
+// SupportedLocaleCombinations are the pairs of locales that the device may be
+// set to at the same time. The first locale of a pair is the primary one.
+var SupportedLocaleCombinations = [][2]SettingLocale{
+	{SettingLocaleEnCA, SettingLocaleFrCA},
+	{SettingLocaleFrCA, SettingLocaleEnCA},
+	{SettingLocaleEnIN, SettingLocaleHiIN},
+	{SettingLocaleHiIN, SettingLocaleEnIN},
+	{SettingLocaleEnUS, SettingLocaleEsUS},
+	{SettingLocaleEsUS, SettingLocaleEnUS},
+}
+
+// Supported returns whether AVS supports the locale.
+func (l SettingLocale) Supported() bool {
+	for _, locale := range SupportedLocales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateLocales returns an error unless the locales are either a single
+// supported locale or one of the SupportedLocaleCombinations.
+func ValidateLocales(locales []SettingLocale) error {
+	switch len(locales) {
+	case 1:
+		if !locales[0].Supported() {
+			return fmt.Errorf("unsupported locale %s", locales[0])
+		}
+		return nil
+	case 2:
+		for _, pair := range SupportedLocaleCombinations {
+			if locales[0] == pair[0] && locales[1] == pair[1] {
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported locale combination %s, %s", locales[0], locales[1])
+	default:
+		return fmt.Errorf("expected 1 or 2 locales, got %d", len(locales))
+	}
+}
+
+// ValidateTimeZone returns an error unless the time zone is a name in the IANA
+// time zone database (e.g., "America/Los_Angeles").
+func ValidateTimeZone(timeZone string) error {
+	if timeZone == "" || timeZone == "Local" {
+		return fmt.Errorf("invalid time zone %q", timeZone)
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", timeZone)
+	}
+	return nil
+}
+
+// StoredSettings are the settings that a SettingsStore persists. Empty values
+// haven't been set.
+type StoredSettings struct {
+	Locales  []SettingLocale `json:"locales,omitempty"`
+	TimeZone string          `json:"timeZone,omitempty"`
+}
+
+// SettingsStore persists the locale and time zone settings so that they
+// survive restarts.
+type SettingsStore interface {
+	// LoadSettings returns the persisted settings, or empty settings if
+	// nothing has been persisted yet.
+	LoadSettings() (StoredSettings, error)
+	SaveSettings(settings StoredSettings) error
+}
+
+// FileSettingsStore is a SettingsStore that keeps the settings in a JSON
+// file.
+type FileSettingsStore string
+
+// LoadSettings implements the SettingsStore interface.
+func (path FileSettingsStore) LoadSettings() (StoredSettings, error) {
+	var settings StoredSettings
+	data, err := ioutil.ReadFile(string(path))
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	err = json.Unmarshal(data, &settings)
+	return settings, err
+}
+
+// SaveSettings implements the SettingsStore interface.
+func (path FileSettingsStore) SaveSettings(settings StoredSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(string(path), data, 0666)
+}
+
+// Settings keeps the locales and time zone of the device, which AVS may change
+// with the SetLocales and SetTimeZone directives and the user may change on
+// the device. The settings are reported to AVS every time the device connects
+// so that AVS always agrees with the device.
+type Settings struct {
+	Sender EventSender
+	// Store is optional. If it's nil, the settings are only kept in memory.
+	Store SettingsStore
+
+	mu       sync.Mutex
+	settings StoredSettings
+}
+
+// NewSettings returns a Settings which restores the settings from the provided
+// store (which may be nil). Invalid persisted values are ignored.
+func NewSettings(sender EventSender, store SettingsStore) (*Settings, error) {
+	s := &Settings{
+		Sender: sender,
+		Store:  store,
+	}
+	if store != nil {
+		settings, err := store.LoadSettings()
+		if err != nil {
+			return nil, err
+		}
+		if ValidateLocales(settings.Locales) == nil {
+			s.settings.Locales = settings.Locales
+		}
+		if ValidateTimeZone(settings.TimeZone) == nil {
+			s.settings.TimeZone = settings.TimeZone
+		}
+	}
+	return s, nil
+}
+
+// Locales returns the locales of the device, or nil if they haven't been set.
+func (s *Settings) Locales() []SettingLocale {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SettingLocale(nil), s.settings.Locales...)
+}
+
+// TimeZone returns the time zone of the device, or an empty string if it
+// hasn't been set.
+func (s *Settings) TimeZone() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings.TimeZone
+}
+
+// SetLocales applies the SetLocales directive and confirms the locales to AVS
+// with the LocalesChanged event. If the locales aren't supported, the current
+// locales are confirmed instead and an error is returned.
+func (s *Settings) SetLocales(d *SetLocales) error {
+	err := s.setLocales(d.Payload.Locales)
+	if reportErr := s.reportLocales(); err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+// SetTimeZone applies the SetTimeZone directive and confirms the time zone to
+// AVS with the TimeZoneChanged event. If the time zone is invalid, the current
+// time zone is confirmed instead and an error is returned.
+func (s *Settings) SetTimeZone(d *SetTimeZone) error {
+	err := s.setTimeZone(d.Payload.TimeZone)
+	if reportErr := s.reportTimeZone(); err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+// ChangeLocales changes the locales because the user changed them on the
+// device, and reports the LocalesChanged event.
+func (s *Settings) ChangeLocales(locales ...SettingLocale) error {
+	if err := s.setLocales(locales); err != nil {
+		return err
+	}
+	return s.reportLocales()
+}
+
+// ChangeTimeZone changes the time zone because the user changed it on the
+// device, and reports the TimeZoneChanged event.
+func (s *Settings) ChangeTimeZone(timeZone string) error {
+	if err := s.setTimeZone(timeZone); err != nil {
+		return err
+	}
+	return s.reportTimeZone()
+}
+
+// Report sends the LocalesChanged and TimeZoneChanged events with the current
+// settings, e.g., after connecting to AVS. Settings that haven't been set
+// aren't reported.
+func (s *Settings) Report() error {
+	if err := s.reportLocales(); err != nil {
+		return err
+	}
+	return s.reportTimeZone()
+}
+
+func (s *Settings) reportLocales() error {
+	locales := s.Locales()
+	if len(locales) == 0 {
+		return nil
+	}
+	_, err := s.Sender.SendEvent(NewLocalesChanged(RandomUUIDString(), locales))
+	return err
+}
+
+func (s *Settings) reportTimeZone() error {
+	timeZone := s.TimeZone()
+	if timeZone == "" {
+		return nil
+	}
+	_, err := s.Sender.SendEvent(NewTimeZoneChanged(RandomUUIDString(), timeZone))
+	return err
+}
+
+// Validates, changes and persists the locales.
+func (s *Settings) setLocales(locales []SettingLocale) error {
+	if err := ValidateLocales(locales); err != nil {
+		return err
+	}
+	return s.update(func(settings *StoredSettings) {
+		settings.Locales = append([]SettingLocale(nil), locales...)
+	})
+}
+
+// Validates, changes and persists the time zone.
+func (s *Settings) setTimeZone(timeZone string) error {
+	if err := ValidateTimeZone(timeZone); err != nil {
+		return err
+	}
+	return s.update(func(settings *StoredSettings) {
+		settings.TimeZone = timeZone
+	})
+}
+
+// Persists the changed settings, and only keeps them if that succeeds.
+func (s *Settings) update(f func(settings *StoredSettings)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	settings := s.settings
+	f(&settings)
+	if s.Store != nil {
+		if err := s.Store.SaveSettings(settings); err != nil {
+			return err
+		}
+	}
+	s.settings = settings
+	return nil
+}
@@ -0,0 +1,219 @@
+package avs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// A SettingsStore which keeps the settings in memory and fails to save them
+// while err is set.
+type memorySettingsStore struct {
+	settings StoredSettings
+	err      error
+}
+
+func (s *memorySettingsStore) LoadSettings() (StoredSettings, error) {
+	return s.settings, nil
+}
+
+func (s *memorySettingsStore) SaveSettings(settings StoredSettings) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.settings = settings
+	return nil
+}
+
+// Returns an EventSender which sends the events to the channel.
+func settingsEvents(events chan<- TypedMessage) EventSender {
+	return EventSenderFunc(func(event TypedMessage) (*Response, error) {
+		events <- event
+		return nil, nil
+	})
+}
+
+func TestValidateLocales(t *testing.T) {
+	valid := [][]SettingLocale{
+		{SettingLocaleEnUS},
+		{SettingLocaleJaJP},
+		{SettingLocaleEnUS, SettingLocaleEsUS},
+		{SettingLocaleEsUS, SettingLocaleEnUS},
+		{SettingLocaleFrCA, SettingLocaleEnCA},
+		{SettingLocaleHiIN, SettingLocaleEnIN},
+	}
+	for _, locales := range valid {
+		if err := ValidateLocales(locales); err != nil {
+			t.Errorf("%v: %v", locales, err)
+		}
+	}
+	invalid := [][]SettingLocale{
+		nil,
+		{},
+		{SettingLocale("xx-XX")},
+		{SettingLocale("en_US")},
+		{SettingLocaleEnUS, SettingLocaleEnUS},
+		{SettingLocaleEnUS, SettingLocaleFrCA},
+		{SettingLocaleEnGB, SettingLocaleDeDE},
+		{SettingLocaleEnCA, SettingLocale("xx-XX")},
+		{SettingLocaleEnUS, SettingLocaleEsUS, SettingLocaleEnCA},
+	}
+	for _, locales := range invalid {
+		if err := ValidateLocales(locales); err == nil {
+			t.Errorf("%v: got no error", locales)
+		}
+	}
+}
+
+func TestValidateTimeZone(t *testing.T) {
+	for _, timeZone := range []string{"America/Los_Angeles", "Europe/Stockholm", "UTC"} {
+		if err := ValidateTimeZone(timeZone); err != nil {
+			t.Errorf("%q: %v", timeZone, err)
+		}
+	}
+	for _, timeZone := range []string{"", "Local", "Mars/Olympus_Mons", "america/los_angeles", "../zoneinfo"} {
+		if err := ValidateTimeZone(timeZone); err == nil {
+			t.Errorf("%q: got no error", timeZone)
+		}
+	}
+}
+
+func TestFileSettingsStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := FileSettingsStore(filepath.Join(dir, "settings.json"))
+	if got, err := store.LoadSettings(); err != nil || !reflect.DeepEqual(got, StoredSettings{}) {
+		t.Errorf("got %v, %v before saving; want empty settings", got, err)
+	}
+	want := StoredSettings{
+		Locales:  []SettingLocale{SettingLocaleEnUS, SettingLocaleEsUS},
+		TimeZone: "America/Los_Angeles",
+	}
+	if err := store.SaveSettings(want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.LoadSettings(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v; want %v", got, err, want)
+	}
+	if err := ioutil.WriteFile(string(store), []byte("{"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoadSettings(); err == nil {
+		t.Error("got no error from a corrupt file")
+	}
+}
+
+func TestNewSettings(t *testing.T) {
+	store := &memorySettingsStore{settings: StoredSettings{
+		Locales:  []SettingLocale{SettingLocaleDeDE},
+		TimeZone: "Europe/Berlin",
+	}}
+	s, err := NewSettings(settingsEvents(make(chan TypedMessage, 10)), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Locales(); !reflect.DeepEqual(got, store.settings.Locales) {
+		t.Errorf("got locales %v, want %v", got, store.settings.Locales)
+	}
+	if got := s.TimeZone(); got != "Europe/Berlin" {
+		t.Errorf("got time zone %q, want %q", got, "Europe/Berlin")
+	}
+	// Invalid persisted values are ignored.
+	store.settings = StoredSettings{
+		Locales:  []SettingLocale{SettingLocaleEnUS, SettingLocaleDeDE},
+		TimeZone: "Mars/Olympus_Mons",
+	}
+	s, err = NewSettings(settingsEvents(make(chan TypedMessage, 10)), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Locales(); got != nil {
+		t.Errorf("got locales %v from invalid settings, want none", got)
+	}
+	if got := s.TimeZone(); got != "" {
+		t.Errorf("got time zone %q from invalid settings, want none", got)
+	}
+}
+
+func TestSettingsSetLocales(t *testing.T) {
+	store := new(memorySettingsStore)
+	events := make(chan TypedMessage, 10)
+	s, err := NewSettings(settingsEvents(events), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SettingLocale{SettingLocaleEnCA, SettingLocaleFrCA}
+	d := new(SetLocales)
+	d.Payload.Locales = want
+	if err := s.SetLocales(d); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Locales(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got locales %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(store.settings.Locales, want) {
+		t.Errorf("got locales %v persisted, want %v", store.settings.Locales, want)
+	}
+	if event, ok := (<-events).(*LocalesChanged); !ok || !reflect.DeepEqual(event.Payload.Locales, want) {
+		t.Errorf("got %v, want LocalesChanged with %v", event, want)
+	}
+	// An unsupported combination is rejected and the current locales are
+	// confirmed instead.
+	d.Payload.Locales = []SettingLocale{SettingLocaleEnCA, SettingLocaleEnUS}
+	if err := s.SetLocales(d); err == nil {
+		t.Error("got no error for an unsupported combination")
+	}
+	if got := s.Locales(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got locales %v after rejecting, want %v", got, want)
+	}
+	if event, ok := (<-events).(*LocalesChanged); !ok || !reflect.DeepEqual(event.Payload.Locales, want) {
+		t.Errorf("got %v, want LocalesChanged with %v", event, want)
+	}
+}
+
+func TestSettingsUnpersistable(t *testing.T) {
+	saveErr := errors.New("read-only")
+	store := &memorySettingsStore{settings: StoredSettings{
+		Locales:  []SettingLocale{SettingLocaleEnGB},
+		TimeZone: "Europe/London",
+	}}
+	events := make(chan TypedMessage, 10)
+	s, err := NewSettings(settingsEvents(events), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.err = saveErr
+	if err := s.ChangeLocales(SettingLocaleFrFR); err != saveErr {
+		t.Errorf("got error %v, want %v", err, saveErr)
+	}
+	if err := s.ChangeTimeZone("Europe/Paris"); err != saveErr {
+		t.Errorf("got error %v, want %v", err, saveErr)
+	}
+	// The settings that couldn't be persisted aren't kept or reported.
+	if got, want := s.Locales(), []SettingLocale{SettingLocaleEnGB}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got locales %v, want %v", got, want)
+	}
+	if got := s.TimeZone(); got != "Europe/London" {
+		t.Errorf("got time zone %q, want %q", got, "Europe/London")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("got %v for settings that weren't persisted", event.GetMessage())
+	default:
+	}
+	// AVS is told the settings haven't changed.
+	d := new(SetTimeZone)
+	d.Payload.TimeZone = "Europe/Paris"
+	if err := s.SetTimeZone(d); err != saveErr {
+		t.Errorf("got error %v, want %v", err, saveErr)
+	}
+	if event, ok := (<-events).(*TimeZoneChanged); !ok || event.Payload.TimeZone != "Europe/London" {
+		t.Errorf("got %v, want TimeZoneChanged with Europe/London", event)
+	}
+}
@@ -0,0 +1,144 @@
+package avs
+
+import (
+	"sync"
+)
+
+// The range of volumes supported by AVS.
+const (
+	MinVolume = 0
+	MaxVolume = 100
+)
+
+// SpeakerHardware controls the volume of the device's speaker.
+type SpeakerHardware interface {
+	// SetVolume sets the volume, which will be between MinVolume and MaxVolume.
+	SetVolume(volume int) error
+	// SetMute mutes or unmutes the speaker.
+	SetMute(muted bool) error
+}
+
+// Speaker applies the Speaker directives to the speaker hardware and reports
+// the VolumeChanged and MuteChanged events to AVS. Local volume controls (such
+// as buttons on the device) should also go through the Speaker so that AVS
+// is kept up to date.
+type Speaker struct {
+	Sender   EventSender
+	Hardware SpeakerHardware
+	// The amount that the volume changes with VolumeUp and VolumeDown.
+	Step int
+
+	mu     sync.Mutex
+	volume int
+	muted  bool
+}
+
+// NewSpeaker returns a Speaker for the provided hardware, which is currently
+// at the provided volume and mute state.
+func NewSpeaker(sender EventSender, hardware SpeakerHardware, volume int, muted bool) *Speaker {
+	return &Speaker{
+		Sender:   sender,
+		Hardware: hardware,
+		Step:     10,
+		volume:   clampVolume(volume),
+		muted:    muted,
+	}
+}
+
+// AdjustVolume applies the AdjustVolume directive.
+func (s *Speaker) AdjustVolume(d *AdjustVolume) error {
+	return s.adjustVolume(d.Payload.Volume)
+}
+
+// SetMute applies the SetMute directive.
+func (s *Speaker) SetMute(d *SetMute) error {
+	return s.setMute(d.Payload.Mute)
+}
+
+// SetVolume applies the SetVolume directive.
+func (s *Speaker) SetVolume(d *SetVolume) error {
+	return s.setVolume(d.Payload.Volume)
+}
+
+// VolumeUp increases the volume by one step, as if the user pressed a volume
+// up button on the device.
+func (s *Speaker) VolumeUp() error {
+	return s.adjustVolume(s.Step)
+}
+
+// VolumeDown decreases the volume by one step, as if the user pressed a
+// volume down button on the device.
+func (s *Speaker) VolumeDown() error {
+	return s.adjustVolume(-s.Step)
+}
+
+// ToggleMute mutes or unmutes the speaker, as if the user pressed a mute
+// button on the device.
+func (s *Speaker) ToggleMute() error {
+	return s.updateMute(func(muted bool) bool { return !muted })
+}
+
+// VolumeState returns the current VolumeState context.
+func (s *Speaker) VolumeState() *VolumeState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return NewVolumeState(s.volume, s.muted)
+}
+
+// Context implements the ContextProvider interface.
+func (s *Speaker) Context() TypedMessage {
+	return s.VolumeState()
+}
+
+func (s *Speaker) adjustVolume(delta int) error {
+	return s.updateVolume(func(volume int) int { return volume + delta })
+}
+
+func (s *Speaker) setMute(muted bool) error {
+	return s.updateMute(func(bool) bool { return muted })
+}
+
+// Calculates a new mute state from the current one and applies it.
+func (s *Speaker) updateMute(f func(muted bool) bool) error {
+	s.mu.Lock()
+	muted := f(s.muted)
+	if err := s.Hardware.SetMute(muted); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.muted = muted
+	event := NewMuteChanged(RandomUUIDString(), s.volume, s.muted)
+	s.mu.Unlock()
+	_, err := s.Sender.SendEvent(event)
+	return err
+}
+
+func (s *Speaker) setVolume(volume int) error {
+	return s.updateVolume(func(int) int { return volume })
+}
+
+// Calculates a new volume from the current one and applies it.
+func (s *Speaker) updateVolume(f func(volume int) int) error {
+	s.mu.Lock()
+	volume := clampVolume(f(s.volume))
+	if err := s.Hardware.SetVolume(volume); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.volume = volume
+	event := NewVolumeChanged(RandomUUIDString(), s.volume, s.muted)
+	s.mu.Unlock()
+	_, err := s.Sender.SendEvent(event)
+	return err
+}
+
+// Limits the volume to the range supported by AVS.
+func clampVolume(volume int) int {
+	if volume < MinVolume {
+		return MinVolume
+	}
+	if volume > MaxVolume {
+		return MaxVolume
+	}
+	return volume
+}
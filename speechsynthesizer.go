@@ -0,0 +1,173 @@
+package avs
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpeechSynthesizer plays the audio of Speak directives through an AudioSink
+// and reports the SpeechStarted and SpeechFinished events to AVS.
+//
+// A SpeechSynthesizer should be told about every new dialog (by calling
+// Interrupt) so that it can stop speech that belongs to a previous dialog.
+type SpeechSynthesizer struct {
+	Sender EventSender
+	Sink   AudioSink
+	// Focus is optional. If it's set, speech uses the dialog channel and is
+	// interrupted if it loses it.
+	Focus *FocusManager
+
+	// Held for the duration of a Speak call so that speech is never overlapping.
+	playing sync.Mutex
+
+	mu              sync.Mutex
+	dialogRequestId string
+	token           string
+	activity        PlayerActivity
+	started         time.Time
+	offset          time.Duration
+	interrupted     bool
+	idle            chan struct{}
+}
+
+// NewSpeechSynthesizer returns a SpeechSynthesizer which reports events with
+// the provided sender and plays audio through the provided sink.
+func NewSpeechSynthesizer(sender EventSender, sink AudioSink) *SpeechSynthesizer {
+	idle := make(chan struct{})
+	close(idle)
+	return &SpeechSynthesizer{
+		Sender:   sender,
+		Sink:     sink,
+		activity: PlayerActivityFinished,
+		idle:     idle,
+	}
+}
+
+// Speak plays the audio referenced by the Speak directive, which must be
+// attached to the response that the directive came with. Speak blocks until
+// the speech has finished or been interrupted.
+//
+// Speak directives that belong to a dialog other than the current one are
+// ignored.
+func (s *SpeechSynthesizer) Speak(d *Speak, response *Response) error {
+	cid := d.ContentId()
+	if cid == "" {
+		return fmt.Errorf("unsupported Speak url %s", d.Payload.URL)
+	}
+	data, ok := response.Content[cid]
+	if !ok {
+		return fmt.Errorf("missing content %s", cid)
+	}
+	s.playing.Lock()
+	defer s.playing.Unlock()
+	s.mu.Lock()
+	if !s.isCurrentDialog(d.Message) {
+		s.mu.Unlock()
+		return nil
+	}
+	idle := make(chan struct{})
+	s.token = d.Payload.Token
+	s.activity = PlayerActivityPlaying
+	s.started = time.Now()
+	s.offset = 0
+	s.interrupted = false
+	s.idle = idle
+	s.mu.Unlock()
+	if s.Focus != nil {
+		s.Focus.Acquire(FocusChannelDialog, s)
+		defer s.Focus.Release(FocusChannelDialog, s)
+	}
+	_, err := s.Sender.SendEvent(NewSpeechStarted(RandomUUIDString(), d.Payload.Token))
+	playErr := s.Sink.Play(d.Payload.Format, bytes.NewReader(data))
+	s.mu.Lock()
+	s.offset = time.Since(s.started)
+	s.activity = PlayerActivityFinished
+	interrupted := s.interrupted
+	close(idle)
+	s.mu.Unlock()
+	if interrupted {
+		// AVS should not be told that interrupted speech finished.
+		return err
+	}
+	if playErr != nil {
+		return playErr
+	}
+	if _, finishErr := s.Sender.SendEvent(NewSpeechFinished(RandomUUIDString(), d.Payload.Token)); err == nil {
+		err = finishErr
+	}
+	return err
+}
+
+// Interrupt tells the SpeechSynthesizer that a new dialog has started (e.g.,
+// because a Recognize event was sent with the provided dialog request id). Any
+// speech that is currently playing will be stopped, and Speak directives from
+// other dialogs will be ignored from now on.
+func (s *SpeechSynthesizer) Interrupt(dialogRequestId string) error {
+	s.mu.Lock()
+	s.dialogRequestId = dialogRequestId
+	if s.activity != PlayerActivityPlaying {
+		s.mu.Unlock()
+		return nil
+	}
+	s.interrupted = true
+	s.mu.Unlock()
+	return s.Sink.Stop()
+}
+
+// FocusChanged implements the FocusObserver interface. Speech that loses the
+// dialog channel (e.g., because the microphone was opened) is interrupted.
+func (s *SpeechSynthesizer) FocusChanged(state FocusState) error {
+	if state != FocusStateNone {
+		return nil
+	}
+	s.mu.Lock()
+	if s.activity != PlayerActivityPlaying {
+		s.mu.Unlock()
+		return nil
+	}
+	s.interrupted = true
+	s.mu.Unlock()
+	return s.Sink.Stop()
+}
+
+// ExpectSpeech blocks until any speech that is currently playing has finished
+// and then returns whether the microphone should be opened for the provided
+// ExpectSpeech directive. It returns false if the directive belongs to a
+// dialog that has since been interrupted.
+func (s *SpeechSynthesizer) ExpectSpeech(d *ExpectSpeech) bool {
+	s.mu.Lock()
+	idle := s.idle
+	s.mu.Unlock()
+	<-idle
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isCurrentDialog(d.Message)
+}
+
+// SpeechState returns the current SpeechState context. The offset of speech
+// that is playing is calculated at the time of the call.
+func (s *SpeechSynthesizer) SpeechState() *SpeechState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset := s.offset
+	if s.activity == PlayerActivityPlaying {
+		offset = time.Since(s.started)
+	}
+	return NewSpeechState(s.token, offset, s.activity)
+}
+
+// Context implements the ContextProvider interface.
+func (s *SpeechSynthesizer) Context() TypedMessage {
+	return s.SpeechState()
+}
+
+// Checks if the message belongs to the current dialog. Messages without a
+// dialog request id are always considered current.
+//
+// This method must be called with s.mu held.
+func (s *SpeechSynthesizer) isCurrentDialog(m *Message) bool {
+	id := m.Header["dialogRequestId"]
+	return id == "" || s.dialogRequestId == "" || id == s.dialogRequestId
+}
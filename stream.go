@@ -0,0 +1,358 @@
+package avs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The maximum depth of playlists within playlists that a StreamResolver
+// expands.
+const maxPlaylistDepth = 5
+
+// The maximum size of a playlist file.
+const maxPlaylistSize = 1 << 20
+
+// StreamError is returned when a remote stream can't be resolved or played.
+type StreamError struct {
+	// The type of error to report to AVS in a PlaybackFailed event.
+	Type MediaErrorType
+	URL  string
+	Err  error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("stream %s: %v", e.URL, e.Err)
+}
+
+// Returns the MediaErrorType to report for an error that happened while
+// playing audio.
+func mediaErrorType(err error) MediaErrorType {
+	if e, ok := err.(*StreamError); ok {
+		return e.Type
+	}
+	return MediaErrorTypeInternalDeviceError
+}
+
+// PlaylistEntry is an item of audio in a resolved stream.
+type PlaylistEntry struct {
+	URL string
+	// The duration of the audio, if the playlist specified it, or zero.
+	Duration time.Duration
+}
+
+// StreamResolver resolves the URLs of remote streams in Play directives, which
+// may point to playlists (M3U, M3U8 and PLS) rather than to audio.
+type StreamResolver struct {
+	// Client is used to fetch URLs. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Resolve returns the entries to play for the stream URL, in order. Nested
+// playlists are expanded if their URLs have a playlist extension. Entries
+// without one are only requested when they're played, so Open also expands
+// nested playlists that can only be recognized by their content type. If the
+// URL doesn't point to a playlist, the only entry is the URL itself.
+func (r *StreamResolver) Resolve(streamURL string) ([]PlaylistEntry, error) {
+	return r.resolve(streamURL, 0)
+}
+
+// Open opens the audio of the stream URL and returns its format (a MIME type)
+// along with it. If the URL points to a playlist, the entries are played one
+// after another, and those that end before the offset are skipped. The rest
+// of the offset, which the caller should skip itself, is returned.
+func (r *StreamResolver) Open(streamURL string, offset time.Duration) (format string, audio io.ReadCloser, rest time.Duration, err error) {
+	resp, body, isPlaylist, err := r.fetch(streamURL)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	if !isPlaylist {
+		// The start of the audio may have been buffered by the playlist check.
+		audio = struct {
+			io.Reader
+			io.Closer
+		}{body, resp.Body}
+		return resp.Header.Get("Content-Type"), audio, offset, nil
+	}
+	entries, err := r.expand(resp, body, 0)
+	resp.Body.Close()
+	if err != nil {
+		return "", nil, 0, err
+	}
+	for len(entries) > 1 && entries[0].Duration > 0 && offset >= entries[0].Duration {
+		offset -= entries[0].Duration
+		entries = entries[1:]
+	}
+	p := &playlistReader{resolver: r, entries: entries}
+	first, err := p.next()
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return first.Header.Get("Content-Type"), p, offset, nil
+}
+
+// Resolves a URL which is depth playlists deep.
+func (r *StreamResolver) resolve(streamURL string, depth int) ([]PlaylistEntry, error) {
+	resp, body, isPlaylist, err := r.fetch(streamURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if !isPlaylist {
+		return []PlaylistEntry{{URL: streamURL}}, nil
+	}
+	return r.expand(resp, body, depth)
+}
+
+// Requests the URL and checks whether the response is a playlist. The body
+// should be read from the returned reader.
+func (r *StreamResolver) fetch(streamURL string) (*http.Response, *bufio.Reader, bool, error) {
+	resp, err := r.get(streamURL)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	body := bufio.NewReader(resp.Body)
+	return resp, body, isPlaylist(resp, body), nil
+}
+
+// Requests the URL and returns the response if it was successful.
+func (r *StreamResolver) get(streamURL string) (*http.Response, error) {
+	if u, err := url.Parse(streamURL); err != nil {
+		return nil, &StreamError{MediaErrorTypeInvalidRequest, streamURL, err}
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, &StreamError{MediaErrorTypeInvalidRequest, streamURL, fmt.Errorf("unsupported scheme %q", u.Scheme)}
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(streamURL)
+	if err != nil {
+		return nil, &StreamError{MediaErrorTypeServiceUnavailable, streamURL, err}
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		errorType := MediaErrorTypeInvalidRequest
+		switch {
+		case resp.StatusCode == http.StatusServiceUnavailable:
+			errorType = MediaErrorTypeServiceUnavailable
+		case resp.StatusCode >= 500:
+			errorType = MediaErrorTypeInternalServerError
+		}
+		return nil, &StreamError{errorType, streamURL, fmt.Errorf("request failed with %s", resp.Status)}
+	}
+	return resp, nil
+}
+
+// Parses a playlist and resolves its entries.
+func (r *StreamResolver) expand(resp *http.Response, body *bufio.Reader, depth int) ([]PlaylistEntry, error) {
+	streamURL := resp.Request.URL.String()
+	if depth >= maxPlaylistDepth {
+		return nil, &StreamError{MediaErrorTypeInternalServerError, streamURL, fmt.Errorf("playlists are nested more than %d deep", maxPlaylistDepth)}
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxPlaylistSize))
+	if err != nil {
+		return nil, &StreamError{MediaErrorTypeServiceUnavailable, streamURL, err}
+	}
+	var entries []PlaylistEntry
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[playlist]")) {
+		entries = parsePLS(data)
+	} else {
+		entries = parseM3U(data)
+	}
+	var resolved []PlaylistEntry
+	for _, entry := range entries {
+		u, err := resp.Request.URL.Parse(entry.URL)
+		if err != nil {
+			return nil, &StreamError{MediaErrorTypeInvalidRequest, entry.URL, err}
+		}
+		entry.URL = u.String()
+		if !hasPlaylistExtension(u) {
+			resolved = append(resolved, entry)
+			continue
+		}
+		nested, err := r.resolve(entry.URL, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, nested...)
+	}
+	if len(resolved) == 0 {
+		return nil, &StreamError{MediaErrorTypeInternalServerError, streamURL, fmt.Errorf("empty playlist")}
+	}
+	return resolved, nil
+}
+
+// Returns whether the response is a playlist, based on its content type, the
+// extension of its URL or, failing that, its content.
+func isPlaylist(resp *http.Response, body *bufio.Reader) bool {
+	mediatype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch strings.ToLower(mediatype) {
+	case "audio/x-mpegurl", "audio/mpegurl", "application/x-mpegurl", "application/vnd.apple.mpegurl",
+		"audio/x-scpls", "application/pls+xml":
+		return true
+	}
+	if hasPlaylistExtension(resp.Request.URL) {
+		return true
+	}
+	start, _ := body.Peek(16)
+	start = bytes.TrimSpace(start)
+	return bytes.HasPrefix(start, []byte("#EXTM3U")) || bytes.HasPrefix(start, []byte("[playlist]"))
+}
+
+func hasPlaylistExtension(u *url.URL) bool {
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".m3u", ".m3u8", ".pls":
+		return true
+	}
+	return false
+}
+
+// Parses an M3U or M3U8 playlist. Only the first variant of an HLS master
+// playlist is returned.
+func parseM3U(data []byte) []PlaylistEntry {
+	var entries []PlaylistEntry
+	var duration time.Duration
+	variant := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.SplitN(strings.TrimPrefix(line, "#EXTINF:"), ",", 2)[0]
+			if seconds, err := strconv.ParseFloat(info, 64); err == nil && seconds > 0 {
+				duration = time.Duration(seconds * float64(time.Second))
+			}
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			variant = true
+		case strings.HasPrefix(line, "#"):
+		default:
+			entries = append(entries, PlaylistEntry{URL: line, Duration: duration})
+			if variant {
+				return entries
+			}
+			duration = 0
+		}
+	}
+	return entries
+}
+
+// Parses a PLS playlist.
+func parsePLS(data []byte) []PlaylistEntry {
+	files := make(map[int]*PlaylistEntry)
+	entry := func(n int) *PlaylistEntry {
+		if files[n] == nil {
+			files[n] = new(PlaylistEntry)
+		}
+		return files[n]
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.ToLower(kv[0]), strings.TrimSpace(kv[1])
+		switch {
+		case strings.HasPrefix(key, "file"):
+			if n, err := strconv.Atoi(key[4:]); err == nil {
+				entry(n).URL = value
+			}
+		case strings.HasPrefix(key, "length"):
+			n, err := strconv.Atoi(key[6:])
+			seconds, err2 := strconv.Atoi(value)
+			if err == nil && err2 == nil && seconds > 0 {
+				entry(n).Duration = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	var numbers []int
+	for n, e := range files {
+		if e.URL != "" {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	entries := make([]PlaylistEntry, len(numbers))
+	for i, n := range numbers {
+		entries[i] = *files[n]
+	}
+	return entries
+}
+
+// Reads the audio of the entries of a playlist one after another.
+type playlistReader struct {
+	resolver *StreamResolver
+	entries  []PlaylistEntry
+	current  io.ReadCloser
+	// How deep the playlists that have been expanded while playing are nested.
+	depth int
+}
+
+// Opens the next entry. If it turns out to be a playlist, which can't always
+// be told from its URL, its entries take its place.
+func (p *playlistReader) next() (*http.Response, error) {
+	for {
+		resp, body, isPlaylist, err := p.resolver.fetch(p.entries[0].URL)
+		if err != nil {
+			return nil, err
+		}
+		if !isPlaylist {
+			p.entries = p.entries[1:]
+			p.current = struct {
+				io.Reader
+				io.Closer
+			}{body, resp.Body}
+			return resp, nil
+		}
+		p.depth++
+		nested, err := p.resolver.expand(resp, body, p.depth)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		p.entries = append(nested, p.entries[1:]...)
+	}
+}
+
+func (p *playlistReader) Read(b []byte) (int, error) {
+	for {
+		if p.current == nil {
+			if len(p.entries) == 0 {
+				return 0, io.EOF
+			}
+			if _, err := p.next(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := p.current.Read(b)
+		if err == io.EOF {
+			p.current.Close()
+			p.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (p *playlistReader) Close() error {
+	p.entries = nil
+	if p.current == nil {
+		return nil
+	}
+	err := p.current.Close()
+	p.current = nil
+	return err
+}
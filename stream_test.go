@@ -0,0 +1,206 @@
+package avs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Starts a server with audio files and playlists.
+func newStreamServer() *httptest.Server {
+	mux := http.NewServeMux()
+	audio := func(content string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "audio/mpeg")
+			fmt.Fprint(w, content)
+		}
+	}
+	playlist := func(contentType, content string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			fmt.Fprint(w, content)
+		}
+	}
+	status := func(code int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}
+	}
+	mux.HandleFunc("/a.mp3", audio("AAAA"))
+	mux.HandleFunc("/b.mp3", audio("BBBB"))
+	mux.HandleFunc("/c.mp3", audio("CCCC"))
+	// Audio that starts like a playlist would, but isn't one.
+	mux.HandleFunc("/stream", audio("ID3 and then a long stream of audio"))
+	mux.HandleFunc("/list.m3u", playlist("", "#EXTM3U\n#EXTINF:30,A\na.mp3\n\n#EXTINF:20,B\n/b.mp3\n"))
+	mux.HandleFunc("/live", playlist("application/vnd.apple.mpegurl", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=64000\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=128000\nhigh/index.m3u8\n"))
+	mux.HandleFunc("/low/index.m3u8", playlist("", "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10.5,\n../a.mp3\n#EXTINF:10.5,\n../b.mp3\n"))
+	mux.HandleFunc("/radio.pls", playlist("", "[playlist]\nFile2=c.mp3\nFile1=b.mp3\nLength1=15\nLength2=-1\nNumberOfEntries=2\n"))
+	mux.HandleFunc("/sniffed", playlist("text/plain", "[playlist]\nFile1=a.mp3\n"))
+	mux.HandleFunc("/nested.m3u", playlist("", "a.mp3\nradio.pls\n"))
+	mux.HandleFunc("/loop.m3u", playlist("", "a.mp3\nloop.m3u\n"))
+	mux.HandleFunc("/empty.pls", playlist("", "[playlist]\nNumberOfEntries=0\n"))
+	mux.HandleFunc("/broken.m3u", playlist("", "a.mp3\nmissing.mp3\n"))
+	// Playlists whose URLs don't have an extension, within a playlist.
+	mux.HandleFunc("/station", playlist("audio/x-scpls", "[playlist]\nFile1=c.mp3\n"))
+	mux.HandleFunc("/favorites", playlist("application/pls+xml", "[playlist]\nFile1=b.mp3\nLength1=15\n"))
+	mux.HandleFunc("/mixed.m3u", playlist("", "a.mp3\nstation\nfavorites\n"))
+	mux.HandleFunc("/forever", playlist("audio/x-mpegurl", "#EXTM3U\nforever\n"))
+	mux.HandleFunc("/busy.mp3", status(http.StatusServiceUnavailable))
+	mux.HandleFunc("/error.mp3", status(http.StatusInternalServerError))
+	return httptest.NewServer(mux)
+}
+
+func TestStreamResolverResolve(t *testing.T) {
+	server := newStreamServer()
+	defer server.Close()
+	u := func(path string) string { return server.URL + path }
+	tests := []struct {
+		url  string
+		want []PlaylistEntry
+	}{
+		{u("/a.mp3"), []PlaylistEntry{{URL: u("/a.mp3")}}},
+		{u("/list.m3u"), []PlaylistEntry{{u("/a.mp3"), 30 * time.Second}, {u("/b.mp3"), 20 * time.Second}}},
+		{u("/live"), []PlaylistEntry{{u("/a.mp3"), 10500 * time.Millisecond}, {u("/b.mp3"), 10500 * time.Millisecond}}},
+		{u("/low/index.m3u8"), []PlaylistEntry{{u("/a.mp3"), 10500 * time.Millisecond}, {u("/b.mp3"), 10500 * time.Millisecond}}},
+		{u("/radio.pls"), []PlaylistEntry{{u("/b.mp3"), 15 * time.Second}, {URL: u("/c.mp3")}}},
+		{u("/sniffed"), []PlaylistEntry{{URL: u("/a.mp3")}}},
+		{u("/nested.m3u"), []PlaylistEntry{{URL: u("/a.mp3")}, {u("/b.mp3"), 15 * time.Second}, {URL: u("/c.mp3")}}},
+	}
+	r := &StreamResolver{}
+	for _, test := range tests {
+		entries, err := r.Resolve(test.url)
+		if err != nil {
+			t.Errorf("%s: %v", test.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(entries, test.want) {
+			t.Errorf("%s: got %v, want %v", test.url, entries, test.want)
+		}
+	}
+}
+
+func TestStreamResolverErrors(t *testing.T) {
+	server := newStreamServer()
+	defer server.Close()
+	// A server that is no longer listening.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	tests := []struct {
+		url  string
+		want MediaErrorType
+	}{
+		{"ftp://example.com/a.mp3", MediaErrorTypeInvalidRequest},
+		{"%zz", MediaErrorTypeInvalidRequest},
+		{server.URL + "/missing.mp3", MediaErrorTypeInvalidRequest},
+		{server.URL + "/busy.mp3", MediaErrorTypeServiceUnavailable},
+		{server.URL + "/error.mp3", MediaErrorTypeInternalServerError},
+		{closed.URL + "/a.mp3", MediaErrorTypeServiceUnavailable},
+		{server.URL + "/empty.pls", MediaErrorTypeInternalServerError},
+		// A playlist that refers to itself is expanded until it's too deep.
+		{server.URL + "/loop.m3u", MediaErrorTypeInternalServerError},
+	}
+	r := &StreamResolver{}
+	for _, test := range tests {
+		_, err := r.Resolve(test.url)
+		if _, ok := err.(*StreamError); !ok {
+			t.Errorf("%s: got error %v, want a StreamError", test.url, err)
+			continue
+		}
+		if got := mediaErrorType(err); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.url, got, test.want)
+		}
+		_, _, _, err = r.Open(test.url, 0)
+		if got := mediaErrorType(err); got != test.want {
+			t.Errorf("%s: Open: got %s, want %s", test.url, got, test.want)
+		}
+	}
+}
+
+func TestStreamResolverOpen(t *testing.T) {
+	server := newStreamServer()
+	defer server.Close()
+	tests := []struct {
+		path   string
+		offset time.Duration
+		format string
+		audio  string
+		rest   time.Duration
+	}{
+		// Audio is passed through whole, including what was read to check
+		// whether it's a playlist.
+		{"/stream", 0, "audio/mpeg", "ID3 and then a long stream of audio", 0},
+		{"/stream", time.Second, "audio/mpeg", "ID3 and then a long stream of audio", time.Second},
+		{"/list.m3u", 0, "audio/mpeg", "AAAABBBB", 0},
+		{"/list.m3u", 10 * time.Second, "audio/mpeg", "AAAABBBB", 10 * time.Second},
+		{"/list.m3u", 35 * time.Second, "audio/mpeg", "BBBB", 5 * time.Second},
+		// The last entry is never skipped.
+		{"/list.m3u", time.Minute, "audio/mpeg", "BBBB", 30 * time.Second},
+		// Entries without a duration can't be skipped.
+		{"/radio.pls", time.Minute, "audio/mpeg", "CCCC", 45 * time.Second},
+		{"/nested.m3u", 0, "audio/mpeg", "AAAABBBBCCCC", 0},
+		// Nested playlists that are only recognized by their content type
+		// are expanded when they're reached.
+		{"/mixed.m3u", 0, "audio/mpeg", "AAAACCCCBBBB", 0},
+		{"/station", 0, "audio/mpeg", "CCCC", 0},
+	}
+	r := &StreamResolver{}
+	for _, test := range tests {
+		format, audio, rest, err := r.Open(server.URL+test.path, test.offset)
+		if err != nil {
+			t.Errorf("%s at %s: %v", test.path, test.offset, err)
+			continue
+		}
+		data, err := ioutil.ReadAll(audio)
+		audio.Close()
+		if err != nil {
+			t.Errorf("%s at %s: %v", test.path, test.offset, err)
+			continue
+		}
+		if format != test.format || string(data) != test.audio || rest != test.rest {
+			t.Errorf("%s at %s: got %q, %q, %s; want %q, %q, %s", test.path, test.offset, format, data, rest, test.format, test.audio, test.rest)
+		}
+	}
+}
+
+func TestStreamResolverOpenMissingEntry(t *testing.T) {
+	server := newStreamServer()
+	defer server.Close()
+	r := &StreamResolver{}
+	_, audio, _, err := r.Open(server.URL+"/broken.m3u", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audio.Close()
+	data, err := ioutil.ReadAll(audio)
+	if string(data) != "AAAA" {
+		t.Errorf("got %q before the missing entry, want %q", data, "AAAA")
+	}
+	if err == nil || !strings.Contains(err.Error(), "missing.mp3") {
+		t.Errorf("got error %v, want an error about missing.mp3", err)
+	}
+	if got := mediaErrorType(err); got != MediaErrorTypeInvalidRequest {
+		t.Errorf("got %s, want %s", got, MediaErrorTypeInvalidRequest)
+	}
+}
+
+func TestStreamResolverOpenNestedLoop(t *testing.T) {
+	server := newStreamServer()
+	defer server.Close()
+	r := &StreamResolver{}
+	// The playlist refers to itself by a URL without an extension, so it
+	// can only be recognized by requesting it.
+	_, _, _, err := r.Open(server.URL+"/forever", 0)
+	if _, ok := err.(*StreamError); !ok {
+		t.Fatalf("got error %v, want a StreamError", err)
+	}
+	if got := mediaErrorType(err); got != MediaErrorTypeInternalServerError {
+		t.Errorf("got %s, want %s", got, MediaErrorTypeInternalServerError)
+	}
+}
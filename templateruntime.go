@@ -0,0 +1,285 @@
+package avs
+
+import (
+	"sync"
+	"time"
+)
+
+// TemplateType specifies the type of a RenderTemplate directive's template.
+type TemplateType string
+
+// Possible values for TemplateType.
+const (
+	// TemplateTypeBodyTemplate1 is a card with a title and text.
+	TemplateTypeBodyTemplate1 = TemplateType("BodyTemplate1")
+	// TemplateTypeBodyTemplate2 is a card with a title, text and an image.
+	TemplateTypeBodyTemplate2 = TemplateType("BodyTemplate2")
+	// TemplateTypeListTemplate1 is a card with a title and a list of items
+	// (e.g., a to-do list).
+	TemplateTypeListTemplate1 = TemplateType("ListTemplate1")
+	// TemplateTypeWeatherTemplate is a card with the current weather and a
+	// forecast.
+	TemplateTypeWeatherTemplate = TemplateType("WeatherTemplate")
+)
+
+// ImageSize specifies the size of an image source.
+type ImageSize string
+
+// Possible values for ImageSize, from smallest to largest.
+const (
+	ImageSizeXSmall = ImageSize("X-SMALL")
+	ImageSizeSmall  = ImageSize("SMALL")
+	ImageSizeMedium = ImageSize("MEDIUM")
+	ImageSizeLarge  = ImageSize("LARGE")
+	ImageSizeXLarge = ImageSize("X-LARGE")
+)
+
+// Image is an image in a template, available in one or more sizes.
+type Image struct {
+	ContentDescription string        `json:"contentDescription,omitempty"`
+	Sources            []ImageSource `json:"sources"`
+}
+
+// Source returns the source of the provided size, or the first source if the
+// image isn't available in that size. It returns nil if the image has no
+// sources.
+func (i *Image) Source(size ImageSize) *ImageSource {
+	if i == nil || len(i.Sources) == 0 {
+		return nil
+	}
+	for n := range i.Sources {
+		if i.Sources[n].Size == size {
+			return &i.Sources[n]
+		}
+	}
+	return &i.Sources[0]
+}
+
+// ImageSource is the URL of an image in a single size.
+type ImageSource struct {
+	URL string `json:"url"`
+	// The URL of a version of the image for dark backgrounds, if any.
+	DarkBackgroundURL string    `json:"darkBackgroundUrl,omitempty"`
+	Size              ImageSize `json:"size,omitempty"`
+	WidthPixels       int       `json:"widthPixels,omitempty"`
+	HeightPixels      int       `json:"heightPixels,omitempty"`
+}
+
+// TemplateTitle is the title of a template.
+type TemplateTitle struct {
+	MainTitle string `json:"mainTitle"`
+	SubTitle  string `json:"subTitle,omitempty"`
+}
+
+// BodyTemplate1 is the payload of a RenderTemplate directive with a title and
+// text.
+type BodyTemplate1 struct {
+	Token     string        `json:"token"`
+	Type      TemplateType  `json:"type"`
+	Title     TemplateTitle `json:"title"`
+	SkillIcon *Image        `json:"skillIcon,omitempty"`
+	TextField string        `json:"textField"`
+}
+
+// BodyTemplate2 is the payload of a RenderTemplate directive with a title,
+// text and an image.
+type BodyTemplate2 struct {
+	Token     string        `json:"token"`
+	Type      TemplateType  `json:"type"`
+	Title     TemplateTitle `json:"title"`
+	SkillIcon *Image        `json:"skillIcon,omitempty"`
+	TextField string        `json:"textField"`
+	Image     *Image        `json:"image,omitempty"`
+}
+
+// ListTemplate1 is the payload of a RenderTemplate directive with a list.
+type ListTemplate1 struct {
+	Token     string        `json:"token"`
+	Type      TemplateType  `json:"type"`
+	Title     TemplateTitle `json:"title"`
+	SkillIcon *Image        `json:"skillIcon,omitempty"`
+	ListItems []ListItem    `json:"listItems"`
+}
+
+// ListItem is an item of a ListTemplate1, e.g., "1." on the left and the text
+// of the item on the right.
+type ListItem struct {
+	LeftTextField  string `json:"leftTextField"`
+	RightTextField string `json:"rightTextField"`
+}
+
+// WeatherTemplate is the payload of a RenderTemplate directive with the
+// weather.
+type WeatherTemplate struct {
+	Token              string             `json:"token"`
+	Type               TemplateType       `json:"type"`
+	Title              TemplateTitle      `json:"title"`
+	SkillIcon          *Image             `json:"skillIcon,omitempty"`
+	CurrentWeather     string             `json:"currentWeather"`
+	Description        string             `json:"description"`
+	CurrentWeatherIcon *Image             `json:"currentWeatherIcon,omitempty"`
+	HighTemperature    WeatherTemperature `json:"highTemperature"`
+	LowTemperature     WeatherTemperature `json:"lowTemperature"`
+	WeatherForecast    []WeatherForecast  `json:"weatherForecast"`
+}
+
+// WeatherTemperature is a formatted temperature (e.g., "75°") with an arrow
+// image.
+type WeatherTemperature struct {
+	Value string `json:"value"`
+	Arrow *Image `json:"arrow,omitempty"`
+}
+
+// WeatherForecast is the forecast for a single day.
+type WeatherForecast struct {
+	Image           *Image `json:"image,omitempty"`
+	Day             string `json:"day"`
+	Date            string `json:"date"`
+	HighTemperature string `json:"highTemperature"`
+	LowTemperature  string `json:"lowTemperature"`
+}
+
+// PlayerContent describes the audio that a RenderPlayerInfo directive is for.
+type PlayerContent struct {
+	Title                     string          `json:"title"`
+	TitleSubtext1             string          `json:"titleSubtext1,omitempty"`
+	TitleSubtext2             string          `json:"titleSubtext2,omitempty"`
+	Header                    string          `json:"header,omitempty"`
+	HeaderSubtext1            string          `json:"headerSubtext1,omitempty"`
+	MediaLengthInMilliseconds float64         `json:"mediaLengthInMilliseconds,omitempty"`
+	Art                       *Image          `json:"art,omitempty"`
+	Provider                  *PlayerProvider `json:"provider,omitempty"`
+}
+
+// MediaLength returns the length of the audio, or zero if it's unknown.
+func (c *PlayerContent) MediaLength() time.Duration {
+	return millisecondsToDuration(c.MediaLengthInMilliseconds)
+}
+
+// PlayerProvider is the music service that provides the audio.
+type PlayerProvider struct {
+	Name string `json:"name"`
+	Logo *Image `json:"logo,omitempty"`
+}
+
+// PlayerControlName identifies a control of the player.
+type PlayerControlName string
+
+// Possible values for PlayerControlName.
+const (
+	PlayerControlNamePlayPause = PlayerControlName("PLAY_PAUSE")
+	PlayerControlNameNext      = PlayerControlName("NEXT")
+	PlayerControlNamePrevious  = PlayerControlName("PREVIOUS")
+)
+
+// PlayerControl is a control that the player should show, such as a next
+// button.
+type PlayerControl struct {
+	Type     string            `json:"type"`
+	Name     PlayerControlName `json:"name"`
+	Enabled  bool              `json:"enabled"`
+	Selected bool              `json:"selected"`
+}
+
+// Display shows the visual cards of the TemplateRuntime interface on the
+// device's screen.
+type Display interface {
+	// RenderTemplate shows a card, such as the weather.
+	RenderTemplate(d *RenderTemplate) error
+	// RenderPlayerInfo shows the now playing card for the audio. The audio item
+	// is the one in the matching Play directive, or nil if that hasn't arrived
+	// yet, in which case RenderPlayerInfo is called again when it does.
+	RenderPlayerInfo(d *RenderPlayerInfo, item *AudioItem) error
+}
+
+// The number of directives that a PlayerInfoLinker keeps while waiting for a
+// match.
+const maxUnlinkedDirectives = 10
+
+// PlayerInfoLinker matches RenderPlayerInfo directives with the Play
+// directives of the audio items that they describe. The two may arrive in
+// either order. A directive is kept until it's linked, and each directive is
+// linked at most once, with the oldest unlinked match.
+type PlayerInfoLinker struct {
+	mu    sync.Mutex
+	infos []*RenderPlayerInfo
+	plays []*Play
+}
+
+// AddPlay links a Play directive with the matching RenderPlayerInfo directive,
+// if it has already been added, and returns it. Otherwise, the Play directive
+// is kept until its RenderPlayerInfo directive is added.
+func (l *PlayerInfoLinker) AddPlay(d *Play) *RenderPlayerInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := d.Payload.AudioItem.AudioItemId
+	for i, info := range l.infos {
+		if info.Payload.AudioItemId == id {
+			l.infos = append(l.infos[:i], l.infos[i+1:]...)
+			return info
+		}
+	}
+	l.plays = append(l.plays, d)
+	if len(l.plays) > maxUnlinkedDirectives {
+		l.plays = l.plays[1:]
+	}
+	return nil
+}
+
+// AddPlayerInfo links a RenderPlayerInfo directive with the matching Play
+// directive, if it has already been added, and returns it. Otherwise, the
+// RenderPlayerInfo directive is kept until its Play directive is added.
+func (l *PlayerInfoLinker) AddPlayerInfo(d *RenderPlayerInfo) *Play {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := d.Payload.AudioItemId
+	for i, play := range l.plays {
+		if play.Payload.AudioItem.AudioItemId == id {
+			l.plays = append(l.plays[:i], l.plays[i+1:]...)
+			return play
+		}
+	}
+	l.infos = append(l.infos, d)
+	if len(l.infos) > maxUnlinkedDirectives {
+		l.infos = l.infos[1:]
+	}
+	return nil
+}
+
+// TemplateRuntime applies the TemplateRuntime directives by passing them on
+// to a Display. Play directives should also be passed to it so that the now
+// playing card can be linked with its audio item.
+type TemplateRuntime struct {
+	Display Display
+
+	linker PlayerInfoLinker
+}
+
+// NewTemplateRuntime returns a TemplateRuntime which shows cards on the
+// provided display.
+func NewTemplateRuntime(display Display) *TemplateRuntime {
+	return &TemplateRuntime{Display: display}
+}
+
+// RenderTemplate applies the RenderTemplate directive.
+func (t *TemplateRuntime) RenderTemplate(d *RenderTemplate) error {
+	return t.Display.RenderTemplate(d)
+}
+
+// RenderPlayerInfo applies the RenderPlayerInfo directive.
+func (t *TemplateRuntime) RenderPlayerInfo(d *RenderPlayerInfo) error {
+	var item *AudioItem
+	if play := t.linker.AddPlayerInfo(d); play != nil {
+		item = &play.Payload.AudioItem
+	}
+	return t.Display.RenderPlayerInfo(d, item)
+}
+
+// Play shows the now playing card again with the audio item, if its
+// RenderPlayerInfo directive arrived before the Play directive.
+func (t *TemplateRuntime) Play(d *Play) error {
+	if info := t.linker.AddPlay(d); info != nil {
+		return t.Display.RenderPlayerInfo(info, &d.Payload.AudioItem)
+	}
+	return nil
+}
@@ -1,15 +1,105 @@
 package avs
 
 import (
+	"fmt"
 	"strings"
 	"time"
 )
 
-// Alert represents a single alarm or timer with a scheduled time.
+// The layouts of the ISO 8601 times that AVS sends. Fractional seconds are
+// accepted by time.Parse even though the layouts don't include them.
+var timeLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05Z07",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04Z0700",
+}
+
+// The layouts of ISO 8601 times without a time zone, which are in UTC.
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+// ParseTime parses an ISO 8601 time, such as the scheduled time of an alert.
+// The seconds may have a fraction and the time zone may be "Z", an offset
+// with or without a colon, or missing, in which case the time is in UTC.
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid ISO 8601 time %q", s)
+}
+
+// FormatTime formats a time the way AVS expects it, in UTC.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Converts a duration in (possibly fractional) milliseconds.
+func millisecondsToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// Converts a duration to milliseconds.
+func durationToMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Alert represents a single alarm, timer or reminder with a scheduled time.
 type Alert struct {
 	Token         string    `json:"token"`
 	Type          AlertType `json:"type"`
 	ScheduledTime string    `json:"scheduledTime"`
+	// The audio to play instead of the built-in tone, if any. The assets are
+	// played in the order of AssetPlayOrder, which holds their ids.
+	Assets         []Asset  `json:"assets,omitempty"`
+	AssetPlayOrder []string `json:"assetPlayOrder,omitempty"`
+	// The id of the asset to play while the alert is in the background.
+	BackgroundAlertAsset string `json:"backgroundAlertAsset,omitempty"`
+	// How many times the assets are played, or zero to play them until the
+	// alert is stopped.
+	LoopCount               int     `json:"loopCount,omitempty"`
+	LoopPauseInMilliSeconds float64 `json:"loopPauseInMilliSeconds,omitempty"`
+	// The text that the user gave the alert (e.g., "take out the trash").
+	Label string `json:"label,omitempty"`
+	// The local time that the user asked for (e.g., "07:00:00.000"), which
+	// doesn't change with the time zone of the device.
+	OriginalTime string `json:"originalTime,omitempty"`
+}
+
+// ScheduledAt returns the parsed scheduled time of the alert.
+func (a *Alert) ScheduledAt() (time.Time, error) {
+	return ParseTime(a.ScheduledTime)
+}
+
+// SetScheduledAt sets the scheduled time of the alert.
+func (a *Alert) SetScheduledAt(t time.Time) {
+	a.ScheduledTime = FormatTime(t)
+}
+
+// LoopPause returns how long to pause between plays of the assets.
+func (a *Alert) LoopPause() time.Duration {
+	return millisecondsToDuration(a.LoopPauseInMilliSeconds)
+}
+
+// Asset returns the asset with the id, or nil.
+func (a *Alert) Asset(id string) *Asset {
+	for i := range a.Assets {
+		if a.Assets[i].AssetId == id {
+			return &a.Assets[i]
+		}
+	}
+	return nil
 }
 
 // AlertType specifies the type of an alert.
@@ -23,8 +113,19 @@ const (
 	// AlertTypeTimer specifies the type for a timer. Timers count down a certain
 	// amount of time (e.g., "timer for 5 minutes").
 	AlertTypeTimer = AlertType("TIMER")
+	// AlertTypeReminder specifies the type for a reminder. Reminders are
+	// scheduled for specific times and have a label (e.g., "remind me to call
+	// mom at 5 PM").
+	AlertTypeReminder = AlertType("REMINDER")
 )
 
+// Asset is audio that a directive refers to, which should be fetched from its
+// URL.
+type Asset struct {
+	AssetId string `json:"assetId"`
+	URL     string `json:"url"`
+}
+
 // AudioItem represents an attached or streamable audio item.
 type AudioItem struct {
 	AudioItemId string `json:"audioItemId"`
@@ -87,6 +188,41 @@ const (
 	PlayBehaviorReplaceEnqueued = PlayBehavior("REPLACE_ENQUEUED")
 )
 
+// PlaybackButton identifies a playback button in a ButtonCommandIssued
+// event.
+type PlaybackButton string
+
+// Possible values for PlaybackButton.
+const (
+	PlaybackButtonPlay         = PlaybackButton("PLAY")
+	PlaybackButtonPause        = PlaybackButton("PAUSE")
+	PlaybackButtonNext         = PlaybackButton("NEXT")
+	PlaybackButtonPrevious     = PlaybackButton("PREVIOUS")
+	PlaybackButtonSkipForward  = PlaybackButton("SKIPFORWARD")
+	PlaybackButtonSkipBackward = PlaybackButton("SKIPBACKWARD")
+)
+
+// PlaybackToggle identifies a playback toggle in a ToggleCommandIssued event.
+type PlaybackToggle string
+
+// Possible values for PlaybackToggle.
+const (
+	PlaybackToggleShuffle    = PlaybackToggle("SHUFFLE")
+	PlaybackToggleLoop       = PlaybackToggle("LOOP")
+	PlaybackToggleRepeat     = PlaybackToggle("REPEAT")
+	PlaybackToggleThumbsUp   = PlaybackToggle("THUMBSUP")
+	PlaybackToggleThumbsDown = PlaybackToggle("THUMBSDOWN")
+)
+
+// ToggleAction specifies whether a playback toggle was turned on or off.
+type ToggleAction string
+
+// Possible values for ToggleAction.
+const (
+	ToggleActionSelect   = ToggleAction("SELECT")
+	ToggleActionDeselect = ToggleAction("DESELECT")
+)
+
 // PlayerActivity specifies what state the audio player is in.
 type PlayerActivity string
 
@@ -100,17 +236,30 @@ const (
 	PlayerActivityFinished       = PlayerActivity("FINISHED")
 )
 
+// ProgressReport specifies when the progress of a stream should be reported.
 type ProgressReport struct {
 	ProgressReportIntervalInMilliseconds float64 `json:"progressReportIntervalInMilliseconds"`
 	ProgressReportDelayInMilliseconds    float64 `json:"progressReportDelayInMilliseconds"`
 }
 
+// Interval returns how often progress should be reported, or zero.
 func (p *ProgressReport) Interval() time.Duration {
-	return time.Duration(p.ProgressReportIntervalInMilliseconds) * time.Millisecond
+	return millisecondsToDuration(p.ProgressReportIntervalInMilliseconds)
 }
 
+// SetInterval sets how often progress should be reported.
+func (p *ProgressReport) SetInterval(d time.Duration) {
+	p.ProgressReportIntervalInMilliseconds = durationToMilliseconds(d)
+}
+
+// Delay returns the offset at which progress should be reported once, or zero.
 func (p *ProgressReport) Delay() time.Duration {
-	return time.Duration(p.ProgressReportDelayInMilliseconds) * time.Millisecond
+	return millisecondsToDuration(p.ProgressReportDelayInMilliseconds)
+}
+
+// SetDelay sets the offset at which progress should be reported once.
+func (p *ProgressReport) SetDelay(d time.Duration) {
+	p.ProgressReportDelayInMilliseconds = durationToMilliseconds(d)
 }
 
 // An audio stream which can either be attached with the response or a remote URL.
@@ -131,3 +280,42 @@ func (s *Stream) ContentId() string {
 	}
 	return s.URL[4:]
 }
+
+// ExpiresAt returns the parsed expiry time of the stream. If the stream
+// doesn't expire, the zero time is returned.
+func (s *Stream) ExpiresAt() (time.Time, error) {
+	if s.ExpiryTime == "" {
+		return time.Time{}, nil
+	}
+	return ParseTime(s.ExpiryTime)
+}
+
+// SetExpiresAt sets the expiry time of the stream. The zero time means that
+// the stream doesn't expire.
+func (s *Stream) SetExpiresAt(t time.Time) {
+	if t.IsZero() {
+		s.ExpiryTime = ""
+		return
+	}
+	s.ExpiryTime = FormatTime(t)
+}
+
+// IsExpired returns whether the stream had expired at the given time. A
+// stream with an invalid expiry time is never considered expired.
+func (s *Stream) IsExpired(now time.Time) bool {
+	expires, err := s.ExpiresAt()
+	if err != nil || expires.IsZero() {
+		return false
+	}
+	return !now.Before(expires)
+}
+
+// Offset returns the offset that the stream should be played from.
+func (s *Stream) Offset() time.Duration {
+	return millisecondsToDuration(s.OffsetInMilliseconds)
+}
+
+// SetOffset sets the offset that the stream should be played from.
+func (s *Stream) SetOffset(d time.Duration) {
+	s.OffsetInMilliseconds = durationToMilliseconds(d)
+}
@@ -0,0 +1,105 @@
+package avs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	date := func(hour, min, sec, nsec int, offset int) time.Time {
+		return time.Date(2017, 8, 14, hour, min, sec, nsec, time.FixedZone("", offset))
+	}
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"2017-08-14T21:30:05Z", date(21, 30, 5, 0, 0)},
+		{"2017-08-14T21:30:05+00:00", date(21, 30, 5, 0, 0)},
+		{"2017-08-14T21:30:05.5Z", date(21, 30, 5, 500000000, 0)},
+		{"2017-08-14T21:30:05.123+0000", date(21, 30, 5, 123000000, 0)},
+		{"2017-08-14T21:30:05.123456789Z", date(21, 30, 5, 123456789, 0)},
+		{"2017-08-14T14:30:05-07:00", date(14, 30, 5, 0, -7*3600)},
+		{"2017-08-14T14:30:05-0700", date(14, 30, 5, 0, -7*3600)},
+		{"2017-08-14T23:30:05+02", date(23, 30, 5, 0, 2*3600)},
+		{"2017-08-14T03:00:05.25+05:30", date(3, 0, 5, 250000000, 5*3600+1800)},
+		{"2017-08-14T21:30Z", date(21, 30, 0, 0, 0)},
+		{"2017-08-14T21:30+0100", date(21, 30, 0, 0, 3600)},
+		// Times without a time zone are in UTC.
+		{"2017-08-14T21:30:05", date(21, 30, 5, 0, 0)},
+		{"2017-08-14T21:30:05.75", date(21, 30, 5, 750000000, 0)},
+		{"2017-08-14T21:30", date(21, 30, 0, 0, 0)},
+		{" 2017-08-14T21:30:05Z\n", date(21, 30, 5, 0, 0)},
+	}
+	for _, test := range tests {
+		got, err := ParseTime(test.s)
+		if err != nil {
+			t.Errorf("%q: %v", test.s, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%q: got %s, want %s", test.s, got, test.want)
+		}
+		_, gotOffset := got.Zone()
+		_, wantOffset := test.want.Zone()
+		if gotOffset != wantOffset {
+			t.Errorf("%q: got offset %d, want %d", test.s, gotOffset, wantOffset)
+		}
+	}
+}
+
+func TestParseTimeInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"2017-08-14",
+		"21:30:05Z",
+		"2017-08-14 21:30:05Z",
+		"2017-13-14T21:30:05Z",
+		"2017-08-14T25:30:05Z",
+		"2017-08-14T21:30:05+7",
+		"2017-08-14T21:30:05 UTC",
+		"yesterday",
+	} {
+		if got, err := ParseTime(s); err == nil {
+			t.Errorf("%q: got %s, want an error", s, got)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	in := time.Date(2017, 8, 14, 14, 30, 5, 500000000, time.FixedZone("", -7*3600))
+	s := FormatTime(in)
+	if want := "2017-08-14T21:30:05.5Z"; s != want {
+		t.Errorf("got %q, want %q", s, want)
+	}
+	if got, err := ParseTime(s); err != nil || !got.Equal(in) {
+		t.Errorf("got %s, %v after parsing %q; want %s", got, err, s, in)
+	}
+}
+
+func TestAlertLoopPause(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Duration
+	}{
+		{`{}`, 0},
+		{`{"loopPauseInMilliSeconds": 0}`, 0},
+		{`{"loopPauseInMilliSeconds": 1500}`, 1500 * time.Millisecond},
+		{`{"loopPauseInMilliSeconds": 2.5}`, 2500 * time.Microsecond},
+		{`{"loopPauseInMilliSeconds": 1e3}`, time.Second},
+	}
+	for _, test := range tests {
+		var alert Alert
+		if err := json.Unmarshal([]byte(test.json), &alert); err != nil {
+			t.Errorf("%s: %v", test.json, err)
+			continue
+		}
+		if got := alert.LoopPause(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.json, got, test.want)
+		}
+	}
+	var alert Alert
+	if err := json.Unmarshal([]byte(`{"loopPauseInMilliSeconds": "1500"}`), &alert); err == nil {
+		t.Errorf("got loop pause %s from a string, want an error", alert.LoopPause())
+	}
+}
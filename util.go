@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/textproto"
 	"strings"
+	"time"
 )
 
 var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
@@ -63,6 +64,25 @@ func escapeQuotes(s string) string {
 	return quoteEscaper.Replace(s)
 }
 
+// The file name and content type of the audio part for each audio format.
+var audioParts = map[RecognizeFormat][2]string{
+	RecognizeFormatL16:  {"audio.wav", "application/octet-stream"},
+	RecognizeFormatOpus: {"audio.opus", "audio/opus"},
+}
+
+// Creates the part of a multipart request that holds audio in the provided
+// format.
+func createAudioPart(writer *multipart.Writer, format RecognizeFormat) (io.Writer, error) {
+	part, ok := audioParts[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported audio format %s", format)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="audio"; filename="%s"`, escapeQuotes(part[0])))
+	h.Set("Content-Type", part[1])
+	return writer.CreatePart(h)
+}
+
 // Encodes a JSON value and writes it to a field with the provided multipart writer.
 func writeJSON(writer *multipart.Writer, fieldname string, value interface{}) error {
 	h := make(textproto.MIMEHeader)
@@ -79,3 +99,22 @@ func writeJSON(writer *multipart.Writer, fieldname string, value interface{}) er
 	_, err = p.Write(data)
 	return err
 }
+
+// Clock provides the current time. It can be replaced in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock which uses the system time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}